@@ -1,11 +1,17 @@
 import fs from "node:fs";
 import path, { dirname } from "node:path";
 import {
+  DURABILITY,
+  DurabilityType,
+  WorkerStatus,
   LOG_LEVEL,
+  LOG_LEVEL_PRIORITY,
   LogLevelType,
   RequestLog,
   LogResponse,
+  WorkerDiagnostic,
   METHOD,
+  VALID_DURABILITIES,
 } from "./protocol.js";
 import { Worker } from "node:worker_threads";
 import { fileURLToPath } from "node:url";
@@ -28,6 +34,58 @@ export type TimestampType =
   | "short" // Short format: 15/01/2024 10:30
   | "custom"; // Custom format (requires customTimestampFormat)
 
+/**
+ * Format log entries are written to log files or the console in
+ */
+export type FileFormatType =
+  | "text" // Same line as the console: [2024-01-15T10:30:00.000Z] [INFO]: message
+  | "json"; // JSON lines: {"ts":"2024-01-15T10:30:00.000Z","level":"INFO","msg":"message"}
+
+/**
+ * Snapshot of what the logger is doing, returned by `logger.status()`
+ */
+export type LoggerStatus = WorkerStatus & {
+  /**
+   * Entries batched in the logger that have not been sent to the worker yet
+   */
+  pendingEntries: number;
+
+  /**
+   * How many entries were dropped since the logger was created, by reason, including the
+   * lines the worker dropped
+   */
+  dropped: Record<string, number>;
+};
+
+/**
+ * A user defined log level
+ */
+export type CustomLogLevel = {
+  /**
+   * Priority compared against the built in levels (DEBUG=10, INFO=20, WARN=30, ERROR=40, FATAL=50)
+   */
+  priority: number;
+
+  /**
+   * Color used for console output, defaults to no color
+   */
+  color?: string;
+};
+
+/**
+ * A log level resolved to what is needed to output it
+ */
+type ResolvedLogLevel = {
+  name: string;
+  priority: number;
+  color: string;
+
+  /**
+   * Built in level sent with the entry, for custom levels the nearest one at or below its priority
+   */
+  level: LogLevelType;
+};
+
 /**
  * Options to change the logger
  */
@@ -48,7 +106,8 @@ export type LoggerOptions = {
   outputToConsole: boolean;
 
   /**
-   * If the output to console should be colored
+   * If the output to console should be colored, only applied when the stream is a terminal that
+   * supports colors and `NO_COLOR` isn't set, `FORCE_COLOR` colors output that isn't a terminal
    */
   useColoredOutput: boolean;
 
@@ -77,6 +136,72 @@ export type LoggerOptions = {
    */
   logLevelMap: Record<LogLevelType, string>;
 
+  /**
+   * Entries below this level are dropped before they are formatted or buffered, either a built in
+   * level such as `LOG_LEVEL.WARN` or the name of a custom level. How many were dropped is logged
+   * every minute. Defaults to logging everything
+   */
+  minLevel?: LogLevelType | string;
+
+  /**
+   * Map of built in levels to a sampling rate N, keeping on average 1 in N entries of that level
+   * for example `{ [LOG_LEVEL.DEBUG]: 100 }`. How many were sampled away is logged every minute
+   */
+  sampleRates?: Partial<Record<LogLevelType, number>>;
+
+  /**
+   * Maximum entries logged per second, entries over the limit are dropped and summarised with a
+   * "suppressed N lines" entry once the rate allows again, or within a minute if nothing else is
+   * logged
+   */
+  maxLinesPerSecond?: number;
+
+  /**
+   * Collapse identical consecutive entries, repeats past the threshold are not logged and are
+   * summarised with a "last message repeated N times" entry once a different entry arrives, and
+   * every minute while the repeats go on
+   */
+  collapseRepeats?: {
+    /**
+     * How long after the previous identical entry a repeat is still collapsed, defaults to 30000
+     */
+    windowMs?: number;
+
+    /**
+     * How many identical entries are logged before further ones are collapsed, defaults to 1
+     */
+    threshold?: number;
+  };
+
+  /**
+   * Keys whose values are replaced with `***` before an entry is output, matched case
+   * insensitively on logged objects and at any depth inside strings holding JSON
+   * for example `["password", "token", "authorization"]`
+   */
+  redactKeys?: string[];
+
+  /**
+   * If emails, credit card numbers and JWTs should be replaced with `***` in every entry
+   */
+  scrubPii?: boolean;
+
+  /**
+   * Additional patterns whose matches are replaced with `***` in every entry
+   */
+  scrubPatterns?: RegExp[];
+
+  /**
+   * Path to a file of additional scrub patterns, one regular expression per line,
+   * empty lines and lines starting with `#` are ignored
+   */
+  scrubPatternsFile?: string;
+
+  /**
+   * Map of additional level names and their definitions for example `{ AUDIT: { priority: 35 } }`,
+   * logged through `logger.custom("AUDIT", ...)`
+   */
+  customLevels?: Record<string, CustomLogLevel>;
+
   /**
    * Show where it was called at (file:line:column)
    */
@@ -111,6 +236,128 @@ export type LoggerOptions = {
     frameIndex?: number;
   };
 
+  /**
+   * Format entries are written to log files in, defaults to `"text"`
+   */
+  fileFormat?: FileFormatType;
+
+  /**
+   * Format entries and the logger's own messages are printed to the console in, defaults to `"text"`.
+   * JSON lines are never colored so tooling reading the output can parse them
+   */
+  consoleFormat?: FileFormatType;
+
+  /**
+   * What a resolved `flush()` guarantees by default: entries accepted by the worker (`DURABILITY.BUFFER`),
+   * handed to the operating system (`DURABILITY.OS`, the default) or fsynced to disk (`DURABILITY.FSYNC`)
+   */
+  flushDurability?: DurabilityType;
+
+  /**
+   * Template used to name log files, supports the tokens `{date}` (YYYY-MM-DD), `{hour}` (HH),
+   * `{host}` (hostname) and `{pid}` (process id) for example `"app-{date}-{host}.log"`. Rotation
+   * suffixes go before the extension, a template ending in a token like `"app.{date}"` has none
+   *
+   * Defaults to `"{date}.log"`
+   */
+  fileNameTemplate?: string;
+
+  /**
+   * IANA time zone used to compute the date in log file names for example `"UTC"` or
+   * `"America/New_York"`, defaults to local time
+   */
+  timeZone?: string;
+
+  /**
+   * Numeric user and group ids to assign the log directory and files to, only applied when the
+   * process is allowed to change ownership (root or CAP_CHOWN)
+   */
+  fileOwner?: {
+    uid: number;
+    gid: number;
+  };
+
+  /**
+   * If a `latest.log` symlink to the current log file should be kept in the base path,
+   * on Windows it is a hard link so no elevated privileges are needed
+   */
+  linkLatestLogFile?: boolean;
+
+  /**
+   * Maximum number of bytes all log files in the base path may use, including those of earlier
+   * processes and the diagnostic, rejected request and write ahead log files. When exceeded the
+   * oldest files are deleted first, write ahead logs and files other running processes are
+   * writing to are kept
+   */
+  maxTotalSize?: number;
+
+  /**
+   * If entries should be appended to a write ahead log (`.node-logy.<host>.<pid>.<thread>.wal` in
+   * the base path) before the worker buffers them, so entries lost to a crash between flushes are
+   * written by the next logger to start on the same host once the process that crashed is gone.
+   * The log is removed on a clean shutdown
+   */
+  writeAheadLog?: boolean;
+
+  /**
+   * If entries at ERROR and above should also be written to a `.error` file next to the combined one,
+   * for example `2026-01-13.error.log`, so errors can be scanned on their own
+   */
+  splitErrorLogFile?: boolean;
+
+  /**
+   * How many of the latest entries below minLevel to keep in memory and write to the `.error` file
+   * ahead of the next ERROR, so a failure comes with the DEBUG lines leading up to it without those
+   * being saved otherwise. Needs splitErrorLogFile, defaults to 0 (disabled)
+   */
+  errorContextSize?: number;
+
+  /**
+   * How many times the worker retries a failed write to the log file, with exponential backoff,
+   * before reporting it, defaults to 3
+   */
+  writeRetries?: number;
+
+  /**
+   * If log files from previous days should be gzip compressed to `.log.gz` when a new file is opened
+   */
+  compressOldLogFiles?: boolean;
+
+  /**
+   * How many of the most recent log entries to keep in memory for `readLast`, defaults to 0 (disabled)
+   */
+  recentEntriesSize?: number;
+
+  /**
+   * If a message containing newlines should be split into one entry per line, each with its
+   * own timestamp and prefixes
+   */
+  splitMultilineMessages?: boolean;
+
+  /**
+   * If the log file should be reopened on SIGHUP, as expected by logrotate and process managers.
+   * This also re-runs compression and the total size limit
+   */
+  reloadOnSighup?: boolean;
+
+  /**
+   * If SIGINT and SIGTERM should flush (fsynced) and shut down the logger before the process exits,
+   * so entries still buffered aren't lost when the process is killed
+   */
+  handleSignals?: boolean;
+
+  /**
+   * How long in milliseconds a signal or process exit waits for the logger to drain before exiting
+   * anyway, defaults to 5000
+   */
+  shutdownTimeoutMs?: number;
+
+  /**
+   * Called with errors from the worker and other background work instead of writing them to stderr,
+   * so the application can react to them
+   */
+  onError?: (error: Error) => void;
+
   /**
    * Contains a list of addtional prefixes to add to each log for example `["foo"]`
    */
@@ -156,6 +403,52 @@ const defaultLoggerOptions: LoggerOptions = {
   },
 };
 
+/**
+ * Value redacted keys are replaced with
+ */
+const REDACTED = "***";
+
+/**
+ * Built in patterns used to scrub personal data when scrubPii is enabled
+ */
+export const PII_PATTERNS = {
+  EMAIL: /[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}/g,
+  // grouped like 4111 1111 1111 1111 or 3782 822463 10005, or bare with a Visa, Mastercard,
+  // Amex or Discover prefix, matches are only scrubbed when they pass the Luhn check
+  CREDIT_CARD:
+    /\b(?:\d{4}([ -])\d{4}\1\d{4}\1\d{4}(?:\1\d{3})?|3[47]\d{2}([ -])\d{6}\2\d{5}|4\d{12}(?:\d{3}){0,2}|5[1-5]\d{14}|2[2-7]\d{14}|3[47]\d{13}|6(?:011|5\d{2}|4[4-9]\d)\d{12,15})\b/g,
+  JWT: /\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+/g,
+} as const;
+
+/**
+ * Checks a card number with the Luhn checksum, separators are ignored
+ * @param value The matched card number
+ */
+const passesLuhn = (value: string): boolean => {
+  const digits = value.replace(/\D/g, "");
+  let sum = 0;
+
+  for (let i = 0; i < digits.length; i++) {
+    let digit = Number(digits[digits.length - 1 - i]);
+    if (i % 2 === 1) {
+      digit *= 2;
+      if (digit > 9) digit -= 9;
+    }
+    sum += digit;
+  }
+
+  return sum % 10 === 0;
+};
+
+/**
+ * Drop reasons that already log a summary of their own, left out of the dropped entries report
+ */
+const SEPARATELY_REPORTED_DROPS = new Set([
+  "sampled",
+  "rateLimited",
+  "collapsed",
+]);
+
 /**
  * Custom error for logger initialization failures
  */
@@ -217,12 +510,113 @@ export class Logger {
   private _logBatchMaxSize = 250;
 
   /**
-   * Holds pending requests that expect a response (FLUSH, RELOAD, SHUTDOWN)
+   * Reloads the log file when SIGHUP is received, set when reloadOnSighup is enabled
+   */
+  private _onSighup: (() => void) | null = null;
+
+  /**
+   * Drains the logger and exits when SIGINT or SIGTERM is received, set when handleSignals is enabled
+   */
+  private _onExitSignal: ((signal: NodeJS.Signals) => void) | null = null;
+
+  /**
+   * Drains the worker when the process exits without the logger being shut down
+   */
+  private _onProcessExit: (() => void) | null = null;
+
+  /**
+   * If entries printed to stdout and stderr are colored, worked out once from the streams
+   * and environment
+   */
+  private _colorStreams = { stdout: false, stderr: false };
+
+  /**
+   * Lower cased keys from the redactKeys option
+   */
+  private _redactKeys: Set<string> = new Set();
+
+  /**
+   * Every pattern entries are scrubbed with, all global
+   */
+  private _scrubPatterns: RegExp[] = [];
+
+  /**
+   * Priority entries must reach to be logged, from the minLevel option
+   */
+  private _minPriority = -Infinity;
+
+  /**
+   * How many entries of each level were sampled away since the last report
+   */
+  private _sampledAway: Map<string, number> = new Map();
+
+  /**
+   * How often the sampled away and dropped counts are reported
+   */
+  private _dropReportMs = 60000;
+
+  /**
+   * Holds the interval reporting the sampled away and dropped counts
+   */
+  private _dropReportInterval: NodeJS.Timeout | null = null;
+
+  /**
+   * Dropped counts by reason as of the last dropped entries report
+   */
+  private _reportedDropped: Record<string, number> = {};
+
+  /**
+   * Tokens left in the maxLinesPerSecond bucket
+   */
+  private _rateTokens = 0;
+
+  /**
+   * When the rate limit bucket was last refilled
+   */
+  private _rateRefilledAt = Date.now();
+
+  /**
+   * How many entries the rate limit dropped since the last report
+   */
+  private _suppressedLines = 0;
+
+  /**
+   * The last entry logged, used to collapse repeats of it
+   */
+  private _lastEntry: {
+    key: string;
+    level: ResolvedLogLevel;
+    at: number;
+    count: number;
+  } | null = null;
+
+  /**
+   * How many entries were dropped since the logger was created, by reason
+   */
+  private _dropped: Map<string, number> = new Map();
+
+  /**
+   * Latest entries below minLevel kept for errorContextSize, oldest first
+   */
+  private _errorContext: RequestLog[] = [];
+
+  /**
+   * Ring buffer of the most recent formatted log entries
+   */
+  private _recentEntries: string[] = [];
+
+  /**
+   * Index in the ring buffer the next entry is written to
+   */
+  private _recentEntriesIndex = 0;
+
+  /**
+   * Holds pending requests that expect a response (FLUSH, RELOAD, ROTATE, SHUTDOWN)
    */
   private _pending: Map<
     number,
     {
-      resolve: (value: void | PromiseLike<void>) => void;
+      resolve: (response: LogResponse) => void;
       reject: (reason?: any) => void;
     }
   > = new Map();
@@ -240,13 +634,106 @@ export class Logger {
 
     this._options = {
       ...defaultLoggerOptions,
+      ...this._getEnvOptions(options),
       ...options,
       colorMap: mergedColorMap,
       logLevelMap: mergedLogLevelMap,
     };
 
     this._validateBasePath();
+    this._validateFileNameTemplate();
+    this._validateCustomLevels();
+    this._validateTimeZone();
+    this._validateFileOwner();
+    this._validateMaxTotalSize();
+    this._validateRecentEntriesSize();
+    this._validateSampleRates();
+    this._validateMaxLinesPerSecond();
+    this._validateShutdownTimeoutMs();
+    this._validateWriteRetries();
+    this._validateFlushDurability();
+    this._validateErrorContextSize();
+    this._rateTokens = this._options.maxLinesPerSecond ?? 0;
+    this._redactKeys = new Set(
+      (this._options.redactKeys ?? []).map((key) => key.toLowerCase()),
+    );
+    this._scrubPatterns = this._loadScrubPatterns();
+    this._colorStreams = {
+      stdout: this._supportsColor(process.stdout),
+      stderr: this._supportsColor(process.stderr),
+    };
+
+    if (this._options.minLevel !== undefined) {
+      const minPriority = this._getLevelPriority(this._options.minLevel);
+      if (minPriority === undefined) {
+        throw new LoggerInitializationError(
+          `minLevel must be a built in or custom level, received ${this._options.minLevel}`,
+        );
+      }
+
+      this._minPriority = minPriority;
+    }
+
     this._initWorker();
+    this._startDropReports();
+    this._listenForSighup();
+    this._listenForExitSignals();
+    this._listenForProcessExit();
+  }
+
+  /**
+   * Read options set through `NODE_LOGGER_*` environment variables, these override the defaults
+   * but are overridden by options passed to the constructor
+   * @param options Options passed to the constructor, variables for options set here are not
+   * validated since they are not used
+   */
+  private _getEnvOptions(
+    options: Partial<LoggerOptions>,
+  ): Partial<LoggerOptions> {
+    const env = process.env;
+    const envOptions: Partial<LoggerOptions> = {};
+
+    const basePath = env["NODE_LOGGER_BASE"];
+    if (basePath) envOptions.basePath = basePath;
+
+    const saveToLogFiles = env["NODE_LOGGER_SAVE"];
+    if (saveToLogFiles) envOptions.saveToLogFiles = saveToLogFiles === "true";
+
+    const fileFormat = env["NODE_LOGGER_FORMAT"];
+    if (fileFormat && options.fileFormat === undefined) {
+      if (fileFormat !== "text" && fileFormat !== "json") {
+        throw new LoggerInitializationError(
+          `NODE_LOGGER_FORMAT must be text or json, received ${fileFormat}`,
+        );
+      }
+      envOptions.fileFormat = fileFormat;
+    }
+
+    const consoleFormat = env["NODE_LOGGER_CONSOLE_FORMAT"];
+    if (consoleFormat && options.consoleFormat === undefined) {
+      if (consoleFormat !== "text" && consoleFormat !== "json") {
+        throw new LoggerInitializationError(
+          `NODE_LOGGER_CONSOLE_FORMAT must be text or json, received ${consoleFormat}`,
+        );
+      }
+      envOptions.consoleFormat = consoleFormat;
+    }
+
+    const minLevel = env["NODE_LOGGER_MIN_LEVEL"];
+    if (minLevel) {
+      const builtIn = Object.entries(LOG_LEVEL).find(
+        ([name]) => name === minLevel.toUpperCase(),
+      );
+      envOptions.minLevel = builtIn ? builtIn[1] : minLevel;
+    }
+
+    const timeZone = env["NODE_LOGGER_TIME_ZONE"];
+    if (timeZone) envOptions.timeZone = timeZone;
+
+    const fileNameTemplate = env["NODE_LOGGER_NAME_TEMPLATE"];
+    if (fileNameTemplate) envOptions.fileNameTemplate = fileNameTemplate;
+
+    return envOptions;
   }
 
   /**
@@ -273,20 +760,37 @@ export class Logger {
         env: {
           BASE_PATH: this._options.basePath,
           SHOULD_SAVE_FILE: `${this._options.saveToLogFiles}`,
+          FILE_NAME_TEMPLATE: this._options.fileNameTemplate ?? "{date}.log",
+          TIME_ZONE: this._options.timeZone ?? "",
+          FILE_OWNER_UID: `${this._options.fileOwner?.uid ?? ""}`,
+          FILE_OWNER_GID: `${this._options.fileOwner?.gid ?? ""}`,
+          LINK_LATEST: `${this._options.linkLatestLogFile ?? false}`,
+          MAX_TOTAL_SIZE: `${this._options.maxTotalSize ?? ""}`,
+          SPLIT_ERROR_LOG: `${this._options.splitErrorLogFile ?? false}`,
+          WRITE_RETRIES: `${this._options.writeRetries ?? 3}`,
+          WRITE_AHEAD_LOG: `${this._options.writeAheadLog ?? false}`,
+          COMPRESS_OLD_LOGS: `${this._options.compressOldLogFiles ?? false}`,
         },
+        // reported below instead of being copied to the process's stderr as well
+        stderr: true,
       });
 
-      this._worker.on("message", (response: LogResponse) => {
-        this._handleResponse(response);
+      this._worker.on("message", (message: LogResponse | WorkerDiagnostic) => {
+        if ("diagnostic" in message) {
+          this._reportDiagnostic(message);
+          return;
+        }
+
+        this._handleResponse(message);
       });
 
       this._worker.stderr.on("data", (chunk) => {
-        process.stderr.write(`Sidecar error: ${chunk.toString()}`);
+        this._reportError(`Sidecar error: ${chunk.toString().trimEnd()}`);
       });
 
       this._worker.on("error", (err) => {
         this._clearPending();
-        process.stderr.write(`Sidecar error: ${this._stringify(err)}`);
+        this._reportError(`Sidecar error: ${this._stringify(err)}`, err);
       });
 
       this._worker.on("exit", () => {
@@ -294,12 +798,184 @@ export class Logger {
         this._worker = null;
       });
     } catch (error) {
-      process.stderr.write(
+      this._reportError(
         `Failed to spawn sidecar: ${this._stringify(error)}`,
+        error,
+      );
+    }
+  }
+
+  /**
+   * Print a message about the logger itself to stderr, as a JSON line marked with
+   * `"source":"logger"` when consoleFormat is json so it can be parsed with the entries
+   */
+  private _writeDiagnostic(level: "INFO" | "ERROR", message: string) {
+    const line =
+      this._options.consoleFormat === "json"
+        ? JSON.stringify({
+            ts: new Date().toISOString(),
+            level,
+            msg: message,
+            source: "logger",
+          })
+        : message;
+
+    process.stderr.write(line + "\n");
+  }
+
+  /**
+   * Report a message the worker sent about itself, errors go to `_reportError` and the rest are
+   * printed to stderr when console output is enabled
+   */
+  private _reportDiagnostic(diagnostic: WorkerDiagnostic) {
+    if (diagnostic.diagnostic === "error") {
+      this._reportError(`Sidecar error: ${diagnostic.message}`);
+      return;
+    }
+
+    if (this._options.outputToConsole) {
+      this._writeDiagnostic("INFO", diagnostic.message);
+    }
+  }
+
+  /**
+   * Pass an error to the onError option, or write it to stderr when it isn't set
+   * @param message What went wrong
+   * @param cause The original error if there is one
+   */
+  private _reportError(message: string, cause?: unknown) {
+    const { onError } = this._options;
+    if (!onError) {
+      this._writeDiagnostic("ERROR", message);
+      return;
+    }
+
+    try {
+      onError(
+        cause === undefined
+          ? new Error(message)
+          : new Error(message, { cause }),
+      );
+    } catch (error) {
+      this._writeDiagnostic(
+        "ERROR",
+        `onError threw: ${this._stringify(error)}`,
       );
     }
   }
 
+  /**
+   * Reload the log file on SIGHUP if enabled
+   */
+  private _listenForSighup() {
+    if (!this._options.reloadOnSighup) return;
+
+    this._onSighup = () => {
+      this.reload().catch((error) => {
+        this._reportError(
+          `Failed to reload on SIGHUP: ${this._stringify(error)}`,
+          error,
+        );
+      });
+    };
+    process.on("SIGHUP", this._onSighup);
+  }
+
+  /**
+   * Stop reloading the log file on SIGHUP
+   */
+  private _stopListeningForSighup() {
+    if (this._onSighup) {
+      process.off("SIGHUP", this._onSighup);
+      this._onSighup = null;
+    }
+  }
+
+  /**
+   * Drain the logger on SIGINT and SIGTERM if enabled, then re-raise the signal so the process
+   * exits the way it would have without the handler
+   */
+  private _listenForExitSignals() {
+    if (!this._options.handleSignals) return;
+
+    this._onExitSignal = (signal) => {
+      this._stopListeningForExitSignals();
+
+      const timeoutMs = this._options.shutdownTimeoutMs ?? 5000;
+      let timeout: NodeJS.Timeout | null = null;
+
+      const drain = this.flush(DURABILITY.FSYNC).then(() => this.shutdown());
+      const timedOut = new Promise<void>((resolve) => {
+        timeout = setTimeout(() => {
+          this._reportError(
+            `Logger did not drain within ${timeoutMs}ms of ${signal}, exiting anyway`,
+          );
+          resolve();
+        }, timeoutMs);
+      });
+
+      Promise.race([drain, timedOut])
+        .catch((error) => {
+          this._reportError(
+            `Failed to drain on ${signal}: ${this._stringify(error)}`,
+            error,
+          );
+        })
+        .finally(() => {
+          if (timeout) clearTimeout(timeout);
+          process.kill(process.pid, signal);
+        });
+    };
+    process.on("SIGINT", this._onExitSignal);
+    process.on("SIGTERM", this._onExitSignal);
+  }
+
+  /**
+   * Drain the worker when the process exits, `exit` listeners can't wait on promises so this
+   * blocks until the worker has written its buffer and closed the files
+   */
+  private _listenForProcessExit() {
+    if (!this._options.saveToLogFiles) return;
+
+    this._onProcessExit = () => {
+      if (!this._worker) return;
+
+      this._reportSampledAway();
+      this._reportSuppressed();
+      this._reportCollapsedRepeats();
+      // the worker can't be asked for its counts while exiting, only the logger's are reported
+      this._reportDropped(Object.fromEntries(this._dropped));
+      this._flushLogBatch();
+
+      const drained = new Int32Array(new SharedArrayBuffer(4));
+      const request: RequestLog = { method: METHOD.DRAIN, drained, payload: "" };
+      this._worker.postMessage([request]);
+      Atomics.wait(drained, 0, 0, this._options.shutdownTimeoutMs ?? 5000);
+    };
+    process.on("exit", this._onProcessExit);
+  }
+
+  /**
+   * Stop draining the worker on process exit
+   */
+  private _stopListeningForProcessExit() {
+    if (this._onProcessExit) {
+      process.off("exit", this._onProcessExit);
+      this._onProcessExit = null;
+    }
+  }
+
+  /**
+   * Stop draining the logger on SIGINT and SIGTERM
+   */
+  private _stopListeningForExitSignals() {
+    if (this._onExitSignal) {
+      process.off("SIGINT", this._onExitSignal);
+      process.off("SIGTERM", this._onExitSignal);
+      this._onExitSignal = null;
+    }
+  }
+
   /**
    * Flush the current log batch to worker immediately
    */
@@ -365,8 +1041,8 @@ export class Logger {
     this._resolvePending(response);
 
     if (!response.success) {
-      process.stderr.write(
-        `Log operation failed: id=${response.id}, method=${response.method}, level=${response.level}\n`,
+      this._reportError(
+        `Log operation failed: id=${response.id}, method=${response.method}, level=${response.level}`,
       );
     }
   }
@@ -379,7 +1055,7 @@ export class Logger {
     if (!pending) return;
 
     if (response.success) {
-      pending.resolve();
+      pending.resolve(response);
     } else {
       pending.reject(new Error("Request failed"));
     }
@@ -387,10 +1063,10 @@ export class Logger {
   }
 
   /**
-   * Send a request that expects a response (FLUSH, RELOAD, SHUTDOWN)
+   * Send a request that expects a response (FLUSH, RELOAD, ROTATE, SHUTDOWN)
    * These are sent immediately, not batched
    */
-  private _sendControlRequest(request: RequestLog): Promise<void> {
+  private _sendControlRequest(request: RequestLog): Promise<LogResponse> {
     const id = request.id;
     if (!id) throw new Error("Request must contain and ID");
 
@@ -406,44 +1082,317 @@ export class Logger {
         }
       }, 4000);
 
-      this._pending.set(id, {
-        reject: (reason) => {
-          clearTimeout(timeout);
-          reject(reason);
-        },
-        resolve: (value) => {
-          clearTimeout(timeout);
-          resolve(value);
-        },
-      });
+      this._pending.set(id, {
+        reject: (reason) => {
+          clearTimeout(timeout);
+          reject(reason);
+        },
+        resolve: (response) => {
+          clearTimeout(timeout);
+          resolve(response);
+        },
+      });
+
+      this._worker?.postMessage([request]);
+    });
+  }
+
+  /**
+   * Validates the basePath option
+   */
+  private _validateBasePath(): void {
+    const { basePath } = this._options;
+
+    if (basePath === undefined || basePath === null) {
+      throw new LoggerInitializationError(
+        "basePath is required and cannot be null or undefined",
+      );
+    }
+
+    if (typeof basePath !== "string") {
+      throw new LoggerInitializationError(
+        `basePath must be a string, received ${typeof basePath}`,
+      );
+    }
+
+    if (basePath.trim().length === 0) {
+      throw new LoggerInitializationError("basePath cannot be an empty string");
+    }
+
+    this._options.basePath = path.resolve(basePath);
+  }
+
+  /**
+   * Validates the fileNameTemplate option
+   */
+  private _validateFileNameTemplate(): void {
+    const { fileNameTemplate } = this._options;
+    if (fileNameTemplate === undefined) return;
+
+    if (typeof fileNameTemplate !== "string") {
+      throw new LoggerInitializationError(
+        `fileNameTemplate must be a string, received ${typeof fileNameTemplate}`,
+      );
+    }
+
+    if (fileNameTemplate.trim().length === 0) {
+      throw new LoggerInitializationError(
+        "fileNameTemplate cannot be an empty string",
+      );
+    }
+
+    if (/[\\/]/.test(fileNameTemplate)) {
+      throw new LoggerInitializationError(
+        "fileNameTemplate cannot contain path separators",
+      );
+    }
+  }
+
+  /**
+   * Validates the timeZone option
+   */
+  private _validateTimeZone(): void {
+    const { timeZone } = this._options;
+    if (timeZone === undefined) return;
+
+    try {
+      new Intl.DateTimeFormat("en-US", { timeZone });
+    } catch {
+      throw new LoggerInitializationError(
+        `timeZone must be a valid IANA time zone, received ${timeZone}`,
+      );
+    }
+  }
+
+  /**
+   * Validates the fileOwner option
+   */
+  private _validateFileOwner(): void {
+    const { fileOwner } = this._options;
+    if (fileOwner === undefined) return;
+
+    if (
+      !Number.isInteger(fileOwner?.uid) ||
+      !Number.isInteger(fileOwner?.gid) ||
+      fileOwner.uid < 0 ||
+      fileOwner.gid < 0
+    ) {
+      throw new LoggerInitializationError(
+        "fileOwner must contain non negative integer uid and gid values",
+      );
+    }
+  }
+
+  /**
+   * Validates the maxTotalSize option
+   */
+  private _validateMaxTotalSize(): void {
+    const { maxTotalSize } = this._options;
+    if (maxTotalSize === undefined) return;
+
+    if (!Number.isFinite(maxTotalSize) || maxTotalSize <= 0) {
+      throw new LoggerInitializationError(
+        `maxTotalSize must be a positive number, received ${maxTotalSize}`,
+      );
+    }
+  }
+
+  /**
+   * Validates the recentEntriesSize option
+   */
+  private _validateRecentEntriesSize(): void {
+    const { recentEntriesSize } = this._options;
+    if (recentEntriesSize === undefined) return;
+
+    if (!Number.isInteger(recentEntriesSize) || recentEntriesSize < 0) {
+      throw new LoggerInitializationError(
+        `recentEntriesSize must be a non negative integer, received ${recentEntriesSize}`,
+      );
+    }
+  }
+
+  /**
+   * Validates the sampleRates option
+   */
+  private _validateSampleRates(): void {
+    const { sampleRates } = this._options;
+    if (sampleRates === undefined) return;
+
+    for (const [level, rate] of Object.entries(sampleRates)) {
+      if (!Number.isFinite(rate) || rate < 1) {
+        throw new LoggerInitializationError(
+          `sampleRates for level ${level} must be a number of at least 1, received ${rate}`,
+        );
+      }
+    }
+  }
+
+  /**
+   * Validates the maxLinesPerSecond option
+   */
+  private _validateMaxLinesPerSecond(): void {
+    const { maxLinesPerSecond } = this._options;
+    if (maxLinesPerSecond === undefined) return;
+
+    if (!Number.isFinite(maxLinesPerSecond) || maxLinesPerSecond < 1) {
+      throw new LoggerInitializationError(
+        `maxLinesPerSecond must be a number of at least 1, received ${maxLinesPerSecond}`,
+      );
+    }
+  }
+
+  /**
+   * Validates the shutdownTimeoutMs option
+   */
+  private _validateShutdownTimeoutMs(): void {
+    const { shutdownTimeoutMs } = this._options;
+    if (shutdownTimeoutMs === undefined) return;
+
+    if (!Number.isFinite(shutdownTimeoutMs) || shutdownTimeoutMs < 0) {
+      throw new LoggerInitializationError(
+        `shutdownTimeoutMs must be a number of at least 0, received ${shutdownTimeoutMs}`,
+      );
+    }
+  }
+
+  /**
+   * Validates the flushDurability option
+   */
+  private _validateFlushDurability(): void {
+    const { flushDurability } = this._options;
+    if (flushDurability === undefined) return;
+
+    if (!VALID_DURABILITIES.has(flushDurability)) {
+      throw new LoggerInitializationError(
+        `flushDurability must be DURABILITY.BUFFER, DURABILITY.OS or DURABILITY.FSYNC, received ${flushDurability}`,
+      );
+    }
+  }
+
+  /**
+   * Validates the writeRetries option
+   */
+  private _validateWriteRetries(): void {
+    const { writeRetries } = this._options;
+    if (writeRetries === undefined) return;
+
+    if (!Number.isInteger(writeRetries) || writeRetries < 0) {
+      throw new LoggerInitializationError(
+        `writeRetries must be a whole number of at least 0, received ${writeRetries}`,
+      );
+    }
+  }
+
+  /**
+   * Validates the errorContextSize option
+   */
+  private _validateErrorContextSize(): void {
+    const { errorContextSize, splitErrorLogFile } = this._options;
+    if (errorContextSize === undefined) return;
+
+    if (!Number.isInteger(errorContextSize) || errorContextSize < 0) {
+      throw new LoggerInitializationError(
+        `errorContextSize must be a whole number of at least 0, received ${errorContextSize}`,
+      );
+    }
+
+    if (errorContextSize > 0 && !splitErrorLogFile) {
+      throw new LoggerInitializationError(
+        "errorContextSize needs splitErrorLogFile, the context is written to the .error file",
+      );
+    }
+  }
+
+  /**
+   * Collects the built in, configured and file based scrub patterns, made global so every match
+   * is replaced
+   */
+  private _loadScrubPatterns(): RegExp[] {
+    const patterns: RegExp[] = [...(this._options.scrubPatterns ?? [])];
+
+    if (this._options.scrubPii) {
+      patterns.push(...Object.values(PII_PATTERNS));
+    }
+
+    const { scrubPatternsFile } = this._options;
+    if (scrubPatternsFile !== undefined) {
+      let lines: string[];
+      try {
+        lines = fs.readFileSync(scrubPatternsFile, "utf-8").split(/\r?\n/);
+      } catch (error) {
+        throw new LoggerInitializationError(
+          `Failed to read scrubPatternsFile: ${(error as Error).message}`,
+        );
+      }
+
+      for (const line of lines) {
+        const source = line.trim();
+        if (source.length === 0 || source.startsWith("#")) continue;
+
+        try {
+          patterns.push(new RegExp(source));
+        } catch {
+          throw new LoggerInitializationError(
+            `scrubPatternsFile contains an invalid pattern: ${source}`,
+          );
+        }
+      }
+    }
+
+    return patterns.map((pattern) =>
+      pattern.global
+        ? pattern
+        : new RegExp(pattern.source, `${pattern.flags}g`),
+    );
+  }
 
-      this._worker?.postMessage([request]);
-    });
+  /**
+   * Replace every scrub pattern match in an entry
+   */
+  private _scrub(content: string): string {
+    let scrubbed = content;
+    for (const pattern of this._scrubPatterns) {
+      scrubbed =
+        pattern === PII_PATTERNS.CREDIT_CARD
+          ? scrubbed.replace(pattern, (match) =>
+              passesLuhn(match) ? REDACTED : match,
+            )
+          : scrubbed.replace(pattern, REDACTED);
+    }
+    return scrubbed;
   }
 
   /**
-   * Validates the basePath option
+   * Validates the customLevels option
    */
-  private _validateBasePath(): void {
-    const { basePath } = this._options;
+  private _validateCustomLevels(): void {
+    const { customLevels } = this._options;
+    if (customLevels === undefined) return;
 
-    if (basePath === undefined || basePath === null) {
-      throw new LoggerInitializationError(
-        "basePath is required and cannot be null or undefined",
-      );
-    }
+    const builtInNames = new Set(Object.values(this._options.logLevelMap));
 
-    if (typeof basePath !== "string") {
-      throw new LoggerInitializationError(
-        `basePath must be a string, received ${typeof basePath}`,
-      );
-    }
+    for (const [name, level] of Object.entries(customLevels)) {
+      if (name.trim().length === 0) {
+        throw new LoggerInitializationError(
+          "customLevels cannot contain an empty level name",
+        );
+      }
 
-    if (basePath.trim().length === 0) {
-      throw new LoggerInitializationError("basePath cannot be an empty string");
-    }
+      if (builtInNames.has(name)) {
+        throw new LoggerInitializationError(
+          `customLevels cannot redefine the built in level ${name}`,
+        );
+      }
 
-    this._options.basePath = path.resolve(basePath);
+      if (
+        typeof level?.priority !== "number" ||
+        !Number.isFinite(level.priority)
+      ) {
+        throw new LoggerInitializationError(
+          `customLevels ${name} must have a finite numeric priority`,
+        );
+      }
+    }
   }
 
   /**
@@ -521,6 +1470,60 @@ export class Logger {
     return this._options.logLevelMap[level] ?? "UNKNOWN";
   }
 
+  /**
+   * Resolve a built in or custom level to its name, priority and color
+   */
+  private _resolveLevel(level: LogLevelType | string): ResolvedLogLevel {
+    if (typeof level === "string") {
+      const custom = this._options.customLevels?.[level];
+      const priority = custom?.priority ?? LOG_LEVEL_PRIORITY[LOG_LEVEL.INFO];
+
+      return {
+        name: level,
+        priority,
+        color: custom?.color ?? Colors.reset,
+        level: this._getNearestBuiltInLevel(priority),
+      };
+    }
+
+    return {
+      name: this._getLevelString(level),
+      priority: LOG_LEVEL_PRIORITY[level],
+      color: this._options.colorMap[level] || Colors.reset,
+      level,
+    };
+  }
+
+  /**
+   * Get the priority of a built in level or a level defined in customLevels
+   */
+  private _getLevelPriority(
+    level: LogLevelType | string,
+  ): number | undefined {
+    return typeof level === "string"
+      ? this._options.customLevels?.[level]?.priority
+      : LOG_LEVEL_PRIORITY[level];
+  }
+
+  /**
+   * Find the most severe built in level whose priority is at or below the given one,
+   * DEBUG when the priority is lower than all of them
+   */
+  private _getNearestBuiltInLevel(priority: number): LogLevelType {
+    let nearest: LogLevelType = LOG_LEVEL.DEBUG;
+
+    for (const level of Object.values(LOG_LEVEL)) {
+      if (
+        LOG_LEVEL_PRIORITY[level] <= priority &&
+        LOG_LEVEL_PRIORITY[level] > LOG_LEVEL_PRIORITY[nearest]
+      ) {
+        nearest = level;
+      }
+    }
+
+    return nearest;
+  }
+
   /**
    * Format timestamp based on the configured timestampType
    */
@@ -574,7 +1577,7 @@ export class Logger {
 
     if (value === null) return "null";
     if (value === undefined) return "undefined";
-    if (valueType === "string") return value;
+    if (valueType === "string") return this._redactJsonString(value);
     if (valueType === "number") return String(value);
     if (valueType === "boolean") return String(value);
     if (valueType === "bigint") return `${value}n`;
@@ -589,15 +1592,18 @@ export class Logger {
         `message: ${value.message}`,
       ];
 
+      const redact = (key: string, propValue: unknown) =>
+        this._redactKeys.has(key.toLowerCase()) ? REDACTED : propValue;
+
       if (value.stack) errorParts.push(`stack: ${value.stack}`);
       if ("code" in value && value.code !== undefined)
-        errorParts.push(`code: ${value.code}`);
+        errorParts.push(`code: ${redact("code", value.code)}`);
       if ("errno" in value && value.errno !== undefined)
-        errorParts.push(`errno: ${value.errno}`);
+        errorParts.push(`errno: ${redact("errno", value.errno)}`);
       if ("syscall" in value && value.syscall !== undefined)
-        errorParts.push(`syscall: ${value.syscall}`);
+        errorParts.push(`syscall: ${redact("syscall", value.syscall)}`);
       if ("path" in value && value.path !== undefined)
-        errorParts.push(`path: ${value.path}`);
+        errorParts.push(`path: ${redact("path", value.path)}`);
 
       // Capture custom properties
       const standardProps = new Set([
@@ -611,6 +1617,11 @@ export class Logger {
       ]);
       for (const prop of Object.getOwnPropertyNames(value)) {
         if (standardProps.has(prop)) continue;
+        if (this._redactKeys.has(prop.toLowerCase())) {
+          errorParts.push(`${prop}: ${REDACTED}`);
+          continue;
+        }
+
         try {
           const propValue = (value as any)[prop];
           errorParts.push(
@@ -629,6 +1640,10 @@ export class Logger {
       if (keys.length === 0) return "{}";
 
       const entries = keys.map((key) => {
+        if (this._redactKeys.has(key.toLowerCase())) {
+          return `${key}: ${REDACTED}`;
+        }
+
         try {
           const val = value[key];
           // Show type for nested objects/arrays, value for primitives
@@ -657,13 +1672,74 @@ export class Logger {
     return String(value);
   }
 
+  /**
+   * Build the message content from the values passed to a log call
+   */
+  private _buildContent(message: any, additionalMessages: any[]): string {
+    const mainMessage = this._stringify(message);
+    const additionalStr = additionalMessages
+      .map((msg) => this._stringify(msg))
+      .join(" ");
+
+    return additionalStr ? `${mainMessage} ${additionalStr}` : mainMessage;
+  }
+
+  /**
+   * Replace the values of redacted keys anywhere inside a parsed JSON value
+   * @param stats Counts how many values were replaced
+   */
+  private _redactJsonValue(
+    value: unknown,
+    stats: { redacted: number },
+  ): unknown {
+    if (Array.isArray(value)) {
+      return value.map((item) => this._redactJsonValue(item, stats));
+    }
+
+    if (value !== null && typeof value === "object") {
+      const redacted: Record<string, unknown> = {};
+      for (const [key, val] of Object.entries(value)) {
+        if (this._redactKeys.has(key.toLowerCase())) {
+          redacted[key] = REDACTED;
+          stats.redacted++;
+        } else {
+          redacted[key] = this._redactJsonValue(val, stats);
+        }
+      }
+      return redacted;
+    }
+
+    return value;
+  }
+
+  /**
+   * Redact keys in a string holding a JSON object or array, other strings and JSON without any
+   * redacted keys are returned as is
+   */
+  private _redactJsonString(value: string): string {
+    if (this._redactKeys.size === 0) return value;
+
+    const trimmed = value.trim();
+    if (!trimmed.startsWith("{") && !trimmed.startsWith("[")) return value;
+
+    try {
+      const stats = { redacted: 0 };
+      const redacted = this._redactJsonValue(JSON.parse(trimmed), stats);
+
+      // re-serializing changes spacing and large numbers so only do it when needed
+      return stats.redacted > 0 ? JSON.stringify(redacted) : value;
+    } catch {
+      return value;
+    }
+  }
+
   /**
    * Format a log message with optional fields
    */
   private _formatMessage(
-    level: LogLevelType,
-    message: any,
-    additionalMessages: any[],
+    levelName: string,
+    content: string,
+    date: Date,
   ): string {
     const parts: string[] = [];
 
@@ -675,14 +1751,13 @@ export class Logger {
 
     // Add timestamp if enabled
     if (this._options.showTimestamps) {
-      const timestamp = this._formatTimestamp(new Date());
+      const timestamp = this._formatTimestamp(date);
       parts.push(`[${timestamp}]`);
     }
 
     // Add log level if enabled
     if (this._options.showLogLevel) {
-      const levelStr = this._getLevelString(level);
-      parts.push(`[${levelStr}]`);
+      parts.push(`[${levelName}]`);
     }
 
     // Add user prefixes
@@ -693,61 +1768,390 @@ export class Logger {
       }
     }
 
-    // Build the message content
-    const mainMessage = this._stringify(message);
-    const additionalStr = additionalMessages
-      .map((msg) => this._stringify(msg))
-      .join(" ");
-
-    const fullMessage = additionalStr
-      ? `${mainMessage} ${additionalStr}`
-      : mainMessage;
-
     // Combine parts with message
     if (parts.length > 0) {
-      return `${parts.join(" ")}: ${fullMessage}`;
+      return `${parts.join(" ")}: ${content}`;
     } else {
-      return fullMessage;
+      return content;
+    }
+  }
+
+  /**
+   * Checks if a console stream should get colors, `NO_COLOR` turns them off and `FORCE_COLOR`
+   * turns them on, otherwise the stream has to be a terminal with color support. Node enables
+   * ANSI escapes in Windows consoles that support them
+   */
+  private _supportsColor(stream: NodeJS.WriteStream): boolean {
+    if (process.env["NO_COLOR"]) return false;
+
+    const forceColor = process.env["FORCE_COLOR"];
+    if (forceColor !== undefined && forceColor !== "") {
+      return forceColor !== "0" && forceColor !== "false";
     }
+
+    return stream.isTTY === true && stream.hasColors();
   }
 
   /**
-   * Apply color to the entire message if colored output is enabled
+   * Apply color to the entire message if colored output is enabled and the stream supports it
    */
-  private _colorize(level: LogLevelType, message: string): string {
-    if (!this._options.useColoredOutput) {
+  private _colorize(
+    color: string,
+    message: string,
+    stream: "stdout" | "stderr",
+  ): string {
+    if (!this._options.useColoredOutput || !this._colorStreams[stream]) {
       return message;
     }
 
-    const color = this._options.colorMap[level] || Colors.reset;
     return `${color}${message}${Colors.reset}`;
   }
 
+  /**
+   * Store an entry in the recent entries ring buffer, overwriting the oldest once full
+   */
+  private _addRecentEntry(entry: string): void {
+    const size = this._options.recentEntriesSize ?? 0;
+    if (size === 0) return;
+
+    if (this._recentEntries.length < size) {
+      this._recentEntries.push(entry);
+    } else {
+      this._recentEntries[this._recentEntriesIndex] = entry;
+    }
+
+    this._recentEntriesIndex = (this._recentEntriesIndex + 1) % size;
+  }
+
+  /**
+   * Starts reporting how many entries were sampled away or dropped, including the rate limited and
+   * collapsed ones as their own reports otherwise wait for a later entry
+   */
+  private _startDropReports() {
+    this._dropReportInterval = setInterval(() => {
+      this._reportSampledAway();
+      this._reportSuppressed();
+      this._reportCollapsedRepeats();
+      this.status().then(
+        ({ dropped }) => this._reportDropped(dropped),
+        (error) =>
+          this._reportError("Failed to report dropped entries", error),
+      );
+    }, this._dropReportMs);
+    this._dropReportInterval.unref();
+  }
+
+  /**
+   * Stops reporting sampled away and dropped entries
+   */
+  private _stopDropReports() {
+    if (this._dropReportInterval) {
+      clearInterval(this._dropReportInterval);
+      this._dropReportInterval = null;
+    }
+  }
+
+  /**
+   * Count an entry dropped for the given reason
+   */
+  private _countDropped(reason: string): void {
+    this._dropped.set(reason, (this._dropped.get(reason) ?? 0) + 1);
+  }
+
+  /**
+   * Checks if an entry should be dropped by sampling, counting it when it is
+   */
+  private _isSampledAway(level: LogLevelType | string): boolean {
+    if (typeof level === "string") return false;
+
+    const rate = this._options.sampleRates?.[level];
+    if (rate === undefined || rate <= 1 || Math.random() < 1 / rate) {
+      return false;
+    }
+
+    const name = this._getLevelString(level);
+    this._sampledAway.set(name, (this._sampledAway.get(name) ?? 0) + 1);
+    this._countDropped("sampled");
+    return true;
+  }
+
+  /**
+   * Logs how many entries of each level were sampled away since the last report
+   */
+  private _reportSampledAway(): void {
+    if (this._sampledAway.size === 0) return;
+
+    const counts = [...this._sampledAway]
+      .map(([name, count]) => `${name}=${count}`)
+      .join(", ");
+    this._sampledAway.clear();
+
+    this._logReport(`Sampled away entries since the last report: ${counts}`);
+  }
+
+  /**
+   * Logs how many entries were dropped since the last report for reasons without a summary of
+   * their own, such as minLevel and the worker's failed writes
+   * @param dropped Dropped counts by reason since the logger was created
+   */
+  private _reportDropped(dropped: Record<string, number>): void {
+    const counts: string[] = [];
+
+    for (const [reason, count] of Object.entries(dropped)) {
+      if (SEPARATELY_REPORTED_DROPS.has(reason)) continue;
+
+      const since = count - (this._reportedDropped[reason] ?? 0);
+      if (since > 0) counts.push(`${reason}=${since}`);
+      this._reportedDropped[reason] = count;
+    }
+
+    if (counts.length === 0) return;
+
+    this._logReport(
+      `Dropped entries since the last report: ${counts.join(", ")}`,
+    );
+  }
+
+  /**
+   * Checks if an entry goes over the maxLinesPerSecond token bucket, counting it when it does
+   */
+  private _isRateLimited(): boolean {
+    const limit = this._options.maxLinesPerSecond;
+    if (limit === undefined) return false;
+
+    const now = Date.now();
+    this._rateTokens = Math.min(
+      limit,
+      this._rateTokens + ((now - this._rateRefilledAt) / 1000) * limit,
+    );
+    this._rateRefilledAt = now;
+
+    if (this._rateTokens < 1) {
+      this._suppressedLines++;
+      this._countDropped("rateLimited");
+      return true;
+    }
+
+    this._rateTokens--;
+    return false;
+  }
+
+  /**
+   * Logs how many entries the rate limit suppressed since the last report
+   */
+  private _reportSuppressed(): void {
+    if (this._suppressedLines === 0) return;
+
+    this._logReport(
+      `Suppressed ${this._suppressedLines} lines over the limit of ${this._options.maxLinesPerSecond} per second`,
+    );
+    this._suppressedLines = 0;
+  }
+
+  /**
+   * Checks if an entry repeats the previous one closely enough to be collapsed, any other entry
+   * first reports how many repeats of the previous one were collapsed
+   */
+  private _isCollapsedRepeat(
+    resolved: ResolvedLogLevel,
+    content: string,
+  ): boolean {
+    const options = this._options.collapseRepeats;
+    if (!options) return false;
+
+    const key = `${resolved.name}:${content}`;
+    const now = Date.now();
+    const last = this._lastEntry;
+
+    const windowMs = options.windowMs ?? 30000;
+
+    if (last && last.key === key && now - last.at <= windowMs) {
+      last.count++;
+      last.at = now;
+
+      const collapsed = last.count > (options.threshold ?? 1);
+      if (collapsed) this._countDropped("collapsed");
+      return collapsed;
+    }
+
+    this._reportCollapsedRepeats();
+    this._lastEntry = { key, level: resolved, at: now, count: 1 };
+    return false;
+  }
+
+  /**
+   * Logs how many times the previous entry was collapsed, at the level of that entry. The count
+   * restarts at the threshold so repeats after the report are still collapsed
+   */
+  private _reportCollapsedRepeats(): void {
+    const last = this._lastEntry;
+    const threshold = this._options.collapseRepeats?.threshold ?? 1;
+    if (!last || last.count <= threshold) return;
+
+    this._logReport(
+      `Last message repeated ${last.count - threshold} times`,
+      last.level,
+    );
+    last.count = threshold;
+  }
+
+  /**
+   * Logs a report about the logger itself, bypassing minLevel, sampling and the rate limit so
+   * the report is never dropped
+   */
+  private _logReport(
+    content: string,
+    resolved: ResolvedLogLevel = this._resolveLevel(LOG_LEVEL.INFO),
+  ): void {
+    const date = new Date();
+
+    this._output(
+      resolved,
+      this._formatMessage(resolved.name, content, date),
+      content,
+      date,
+    );
+  }
+
   /**
    * Log a specific level and content
    * @param level The specific level to log
    * @param message The content of the message
    * @param messages Any additional messages
    */
-  private log(level: LogLevelType, message: any, ...messages: any[]): void {
-    const formattedMessage = this._formatMessage(level, message, messages);
+  private log(
+    level: LogLevelType | string,
+    message: any,
+    ...messages: any[]
+  ): void {
+    const resolved = this._resolveLevel(level);
+    if (resolved.priority < this._minPriority) {
+      this._countDropped("minLevel");
+      this._addErrorContext(resolved, message, messages);
+      return;
+    }
+    if (this._isSampledAway(level)) return;
+    if (this._isRateLimited()) return;
+
+    this._reportSuppressed();
+
+    const content = this._scrub(this._buildContent(message, messages));
+    if (this._isCollapsedRepeat(resolved, content)) return;
+
+    const lines = this._options.splitMultilineMessages
+      ? content.split(/\r?\n/).filter((line) => line.trim().length > 0)
+      : [content];
+    // blank and whitespace only lines are skipped but every call still logs an entry
+    const entries = lines.length > 0 ? lines : [""];
+
+    for (const entry of entries) {
+      const date = new Date();
+      const formattedMessage = this._formatMessage(resolved.name, entry, date);
+      this._output(resolved, formattedMessage, entry, date);
+    }
+  }
+
+  /**
+   * Keeps an entry below minLevel as context for the next error when errorContextSize is set,
+   * the oldest is dropped once it is full
+   */
+  private _addErrorContext(
+    resolved: ResolvedLogLevel,
+    message: any,
+    messages: any[],
+  ): void {
+    const size = this._options.errorContextSize ?? 0;
+    if (size === 0 || !this._options.saveToLogFiles) return;
+
+    const content = this._scrub(this._buildContent(message, messages));
+    const date = new Date();
+
+    this._errorContext.push({
+      method: METHOD.LOG,
+      level: resolved.level,
+      errorContext: true,
+      payload: this._formatEntry(
+        this._options.fileFormat,
+        resolved,
+        this._formatMessage(resolved.name, content, date),
+        content,
+        date,
+      ),
+    });
+    if (this._errorContext.length > size) this._errorContext.shift();
+  }
+
+  /**
+   * Build the line written to the log file or console for an entry
+   */
+  private _formatEntry(
+    format: FileFormatType | undefined,
+    resolved: ResolvedLogLevel,
+    formattedMessage: string,
+    content: string,
+    date: Date,
+  ): string {
+    if (format !== "json") {
+      return formattedMessage;
+    }
+
+    return JSON.stringify({
+      ts: date.toISOString(),
+      level: resolved.name,
+      msg: content,
+    });
+  }
+
+  /**
+   * Send a formatted entry to the log file and console
+   */
+  private _output(
+    resolved: ResolvedLogLevel,
+    formattedMessage: string,
+    content: string,
+    date: Date,
+  ): void {
+    this._addRecentEntry(formattedMessage);
 
     if (this._options.saveToLogFiles) {
+      // sent ahead of the error so the .error file reads in order
+      if (resolved.priority >= LOG_LEVEL_PRIORITY[LOG_LEVEL.ERROR]) {
+        for (const entry of this._errorContext) this._addToLogBatch(entry);
+        this._errorContext = [];
+      }
+
       this._addToLogBatch({
-        // we don't need ID and level
+        // we don't need ID
         method: METHOD.LOG,
-        payload: formattedMessage,
+        level: resolved.level,
+        payload: this._formatEntry(
+          this._options.fileFormat,
+          resolved,
+          formattedMessage,
+          content,
+          date,
+        ),
       });
     }
 
     if (this._options.outputToConsole) {
-      const coloredMessage = this._colorize(level, formattedMessage);
-
-      if (level === LOG_LEVEL.ERROR || level === LOG_LEVEL.FATAL) {
-        process.stderr.write(coloredMessage + "\n");
-      } else {
-        process.stdout.write(coloredMessage + "\n");
-      }
+      const stream =
+        resolved.priority >= LOG_LEVEL_PRIORITY[LOG_LEVEL.ERROR]
+          ? "stderr"
+          : "stdout";
+
+      const line =
+        this._options.consoleFormat === "json"
+          ? this._formatEntry(
+              "json",
+              resolved,
+              formattedMessage,
+              content,
+              date,
+            )
+          : this._colorize(resolved.color, formattedMessage, stream);
+
+      process[stream].write(line + "\n");
     }
   }
 
@@ -786,19 +2190,73 @@ export class Logger {
     this.log(LOG_LEVEL.FATAL, message, ...messages);
   }
 
+  /**
+   * Log using a level defined in the customLevels option
+   * @param level Name of the custom level for example `"AUDIT"`
+   * @throws If the level isn't in customLevels, so a misspelled name isn't logged as INFO
+   */
+  custom(level: string, message: any, ...messages: any[]): void {
+    if (this._getLevelPriority(level) === undefined) {
+      throw new Error(`Unknown log level: ${level}`);
+    }
+
+    this.log(level, message, ...messages);
+  }
+
+  /**
+   * Read the most recent log entries kept in memory, oldest first. Entries are kept regardless
+   * of whether writing them to the log file succeeded
+   * @param count How many entries to return, defaults to all that are kept
+   * @returns The formatted entries
+   */
+  readLast(count?: number): string[] {
+    const ordered =
+      this._recentEntries.length < (this._options.recentEntriesSize ?? 0)
+        ? [...this._recentEntries]
+        : [
+            ...this._recentEntries.slice(this._recentEntriesIndex),
+            ...this._recentEntries.slice(0, this._recentEntriesIndex),
+          ];
+
+    if (count === undefined) return ordered;
+    return count > 0 ? ordered.slice(-count) : [];
+  }
+
+  /**
+   * Change the minimum level while running, for example to temporarily turn on debug logging
+   * @param level The new minimum level, `undefined` logs everything
+   */
+  setMinLevel(level: LogLevelType | string | undefined): void {
+    if (level === undefined) {
+      delete this._options.minLevel;
+      this._minPriority = -Infinity;
+      return;
+    }
+
+    const priority = this._getLevelPriority(level);
+    if (priority === undefined) {
+      throw new Error(`Unknown log level: ${level}`);
+    }
+
+    this._options.minLevel = level;
+    this._minPriority = priority;
+  }
+
   /**
    * Flush remaining buffer to log files
+   * @param durability What the returned promise resolving guarantees, defaults to the flushDurability option
    */
-  flush(): Promise<void> {
+  async flush(durability?: DurabilityType): Promise<void> {
     if (!this._options.saveToLogFiles) {
-      return Promise.resolve();
+      return;
     }
     this._flushLogBatch();
 
-    return this._sendControlRequest({
+    await this._sendControlRequest({
       id: this._getNextId(),
       level: LOG_LEVEL.INFO,
       method: METHOD.FLUSH,
+      durability: durability ?? this._options.flushDurability ?? DURABILITY.OS,
       payload: "",
     });
   }
@@ -806,13 +2264,13 @@ export class Logger {
   /**
    * Used to reload / refresh the process
    */
-  reload(): Promise<void> {
+  async reload(): Promise<void> {
     if (!this._options.saveToLogFiles) {
-      return Promise.resolve();
+      return;
     }
     this._flushLogBatch();
 
-    return this._sendControlRequest({
+    await this._sendControlRequest({
       id: this._getNextId(),
       level: LOG_LEVEL.INFO,
       method: METHOD.RELOAD,
@@ -820,10 +2278,74 @@ export class Logger {
     });
   }
 
+  /**
+   * Close the current log file and continue in a new one with an incrementing suffix
+   * (`YYYY-MM-DD.1.log`, `YYYY-MM-DD.2.log`, ...) for example to snapshot a file mid day
+   */
+  async rotate(): Promise<void> {
+    if (!this._options.saveToLogFiles) {
+      return;
+    }
+    this._flushLogBatch();
+
+    await this._sendControlRequest({
+      id: this._getNextId(),
+      level: LOG_LEVEL.INFO,
+      method: METHOD.ROTATE,
+      payload: "",
+    });
+  }
+
+  /**
+   * Get a snapshot of the current log file, buffers, last flush, dropped entries and what the
+   * next maxTotalSize check will delete
+   */
+  async status(): Promise<LoggerStatus> {
+    let workerStatus: WorkerStatus = {
+      filePath: null,
+      bytesWritten: 0,
+      bufferedEntries: 0,
+      lastFlushAt: null,
+      dropped: {},
+      retention: null,
+    };
+
+    if (this._options.saveToLogFiles) {
+      const response = await this._sendControlRequest({
+        id: this._getNextId(),
+        level: LOG_LEVEL.INFO,
+        method: METHOD.STATUS,
+        payload: "",
+      });
+
+      workerStatus = response.status ?? workerStatus;
+    }
+
+    const dropped = { ...workerStatus.dropped };
+    for (const [reason, count] of this._dropped) {
+      dropped[reason] = (dropped[reason] ?? 0) + count;
+    }
+
+    return {
+      ...workerStatus,
+      pendingEntries: this._logBatch.length,
+      dropped,
+    };
+  }
+
   /**
    * Used to shut down the child process and clean up
    */
   async shutdown(): Promise<void> {
+    this._stopDropReports();
+    this._stopListeningForSighup();
+    this._stopListeningForExitSignals();
+    this._stopListeningForProcessExit();
+    this._reportSampledAway();
+    this._reportSuppressed();
+    this._reportCollapsedRepeats();
+    this._reportDropped((await this.status()).dropped);
+
     if (!this._options.saveToLogFiles) {
       return Promise.resolve();
     }
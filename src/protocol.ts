@@ -21,6 +21,22 @@ export const METHOD = {
    * Close the child process
    */
   SHUTDOWN: 0x04,
+
+  /**
+   * Close the current log file and continue in a new one with an incrementing suffix
+   */
+  ROTATE: 0x05,
+
+  /**
+   * Get the current state of the worker
+   */
+  STATUS: 0x06,
+
+  /**
+   * Write everything buffered and close the log files while the parent blocks on process exit,
+   * the worker signals it is done through the request's `drained` array
+   */
+  DRAIN: 0x07,
 } as const;
 
 /**
@@ -63,6 +79,17 @@ export const LOG_LEVEL = {
   FATAL: 0x05,
 } as const;
 
+/**
+ * Priority of each log level, higher values are more severe
+ */
+export const LOG_LEVEL_PRIORITY: Record<LogLevelType, number> = {
+  [LOG_LEVEL.DEBUG]: 10,
+  [LOG_LEVEL.INFO]: 20,
+  [LOG_LEVEL.WARN]: 30,
+  [LOG_LEVEL.ERROR]: 40,
+  [LOG_LEVEL.FATAL]: 50,
+};
+
 /**
  * Contains a set of valid log levels
  */
@@ -73,6 +100,36 @@ export const VALID_LOG_LEVELS: Set<number> = new Set(Object.values(LOG_LEVEL));
  */
 export type LogLevelType = (typeof LOG_LEVEL)[keyof typeof LOG_LEVEL];
 
+/**
+ * Contains what a FLUSH response guarantees has happened to the flushed entries
+ */
+export const DURABILITY = {
+  /**
+   * Entries were accepted into the worker's buffer
+   */
+  BUFFER: 0x01,
+
+  /**
+   * Entries were handed to the operating system
+   */
+  OS: 0x02,
+
+  /**
+   * Entries were fsynced to disk
+   */
+  FSYNC: 0x03,
+} as const;
+
+/**
+ * Contains a set of valid durabilities
+ */
+export const VALID_DURABILITIES: Set<number> = new Set(Object.values(DURABILITY));
+
+/**
+ * What value the durability can be for a FLUSH request
+ */
+export type DurabilityType = (typeof DURABILITY)[keyof typeof DURABILITY];
+
 /**
  * Represents a request message object used to send messages to the log stream.
  */
@@ -88,16 +145,89 @@ export type RequestLog = {
   method: MethodType;
 
   /**
-   * Log severity level (e.g., INFO, WARN, ERROR). Only present when method is not "LOG".
+   * Log severity level (e.g., INFO, WARN, ERROR). Entries logged with a custom level carry the
+   * nearest built in level at or below its priority.
    */
   level?: LogLevelType;
 
+  /**
+   * What the response to a FLUSH request guarantees, defaults to OS. Only present when method is "FLUSH".
+   */
+  durability?: DurabilityType;
+
+  /**
+   * Shared with the parent, set to 1 and notified once the drain has finished. Only present when
+   * method is "DRAIN".
+   */
+  drained?: Int32Array;
+
+  /**
+   * If the entry is only context for the error after it, written to the errors only log file and
+   * not the combined one. Only present when method is "LOG".
+   */
+  errorContext?: boolean;
+
   /**
    * Message payload.
    */
   payload: string;
 };
 
+/**
+ * Represents the state of the worker, sent in response to a STATUS request
+ */
+export type WorkerStatus = {
+  /**
+   * Path of the log file currently being written to
+   */
+  filePath: string | null;
+
+  /**
+   * Bytes written to the current log file since it was opened
+   */
+  bytesWritten: number;
+
+  /**
+   * Entries buffered in the worker waiting to be written
+   */
+  bufferedEntries: number;
+
+  /**
+   * When the buffer was last written to the file as a unix timestamp in milliseconds
+   */
+  lastFlushAt: number | null;
+
+  /**
+   * How many lines the worker dropped since it started, by reason
+   */
+  dropped: Record<string, number>;
+
+  /**
+   * What the next maxTotalSize check will delete, null when there is no limit
+   */
+  retention: RetentionPlan | null;
+};
+
+/**
+ * Files the next maxTotalSize check would delete if nothing changes before it runs
+ */
+export type RetentionPlan = {
+  /**
+   * When the next check runs as a unix timestamp in milliseconds
+   */
+  nextSweepAt: number;
+
+  /**
+   * Names of the files it would delete, oldest first
+   */
+  candidates: string[];
+
+  /**
+   * Bytes the candidates use
+   */
+  candidateBytes: number;
+};
+
 /**
  * Represents a response message object used to send responses from the log stream.
  */
@@ -121,4 +251,25 @@ export type LogResponse = {
    * Whether the request succeeded (true) or failed (false)
    */
   success: boolean;
+
+  /**
+   * State of the worker. Only present when method is "STATUS".
+   */
+  status?: WorkerStatus;
+};
+
+/**
+ * Message the worker sends about itself, such as a rollover or a failed write, for the logger
+ * to report
+ */
+export type WorkerDiagnostic = {
+  /**
+   * If the message is an error or informational
+   */
+  diagnostic: "error" | "info";
+
+  /**
+   * What happened
+   */
+  message: string;
 };
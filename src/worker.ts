@@ -3,9 +3,24 @@
  */
 
 import path from "node:path";
-import { METHOD, LogResponse, RequestLog } from "./protocol.js";
+import {
+  METHOD,
+  DURABILITY,
+  LOG_LEVEL,
+  LOG_LEVEL_PRIORITY,
+  LogResponse,
+  RequestLog,
+  RetentionPlan,
+  VALID_DURABILITIES,
+  VALID_LOG_LEVELS,
+  VALID_METHODS,
+  WorkerDiagnostic,
+} from "./protocol.js";
 import fs from "node:fs";
-import { parentPort } from "worker_threads";
+import os from "node:os";
+import zlib from "node:zlib";
+import { pipeline } from "node:stream/promises";
+import { parentPort, threadId } from "worker_threads";
 
 /**
  * Used for successful exits
@@ -17,11 +32,193 @@ const EXIT_SUCCESS = 0;
  */
 let fileStream: fs.WriteStream | null = null;
 
+/**
+ * Name of the log file the stream is currently writing to
+ */
+let currentFileName = "";
+
+/**
+ * When the buffer was last written to the file as a unix timestamp in milliseconds
+ */
+let lastFlushAt: number | null = null;
+
+/**
+ * How many times the current day's file was rotated on demand, used as the file name suffix
+ */
+let rotationIndex = 0;
+
+/**
+ * How many entries and write errors the current log file has had, reported when it is closed
+ */
+let fileStats = { entries: 0, errors: 0 };
+
 /**
  * Holds the base path of where to save the log files
  */
 let basePath = "./logs";
 
+/**
+ * If log files other than the one currently being written to should be gzip compressed
+ */
+let compressOldLogs = false;
+
+/**
+ * The latest compression pass, later passes wait for it to finish
+ */
+let compression: Promise<void> = Promise.resolve();
+
+/**
+ * The latest update of `latest.log`, later updates wait for it so they never share the temporary link
+ */
+let latestLinkUpdate: Promise<void> = Promise.resolve();
+
+/**
+ * Template used to build log file names, supports the `{date}`, `{hour}`, `{host}` and `{pid}` tokens
+ */
+let fileNameTemplate = "{date}.log";
+
+/**
+ * IANA time zone used for the dates in file names for example `UTC`, local time when not set
+ */
+let timeZone: string | undefined;
+
+/**
+ * User and group ids created files are assigned to, left as the process owner when not set
+ */
+let fileOwner: { uid: number; gid: number } | null = null;
+
+/**
+ * If a `latest.log` link to the current log file should be maintained
+ */
+let linkLatest = false;
+
+/**
+ * File descriptor of the write ahead log, null when it is disabled
+ */
+let walFd: number | null = null;
+
+/**
+ * Name of this worker's write ahead log holding entries that are not yet written to the log
+ * file, keyed by host, pid and thread so processes sharing a base path each keep their own
+ */
+const WAL_FILE_NAME = `.node-logy.${os.hostname()}.${process.pid}.${threadId}.wal`;
+
+/**
+ * Name this worker gives a write ahead log it took over from an owner that is gone, while it
+ * moves the entries into its own
+ */
+const CLAIMED_WAL_FILE_NAME = `.node-logy.${os.hostname()}.${process.pid}.${threadId}.claimed.wal`;
+
+/**
+ * Matches write ahead log names, capturing the owner's host, pid and thread. `.node-logy.wal`
+ * was used before logs were keyed by owner and has no owner
+ */
+const WAL_FILE_PATTERN =
+  /^\.node-logy(?:\.(.+)\.(\d+)\.(\d+))?(?:\.claimed)?\.wal$/;
+
+/**
+ * How many writes to the log file haven't completed yet
+ */
+let pendingWrites = 0;
+
+/**
+ * How many times a failed write is retried before it is reported
+ */
+let writeRetries = 3;
+
+/**
+ * Delay before the first retry of a failed write, doubled on each one after
+ */
+const WRITE_RETRY_BASE_MS = 100;
+
+/**
+ * Lines held in memory while the disk is full, null when writes are going to the file
+ */
+let diskFullBuffer: string[] | null = null;
+
+/**
+ * Most lines held while the disk is full, the oldest are dropped past this
+ */
+const DISK_FULL_BUFFER_LINES = 10_000;
+
+/**
+ * How many held lines were dropped since the disk became full
+ */
+let diskFullDropped = 0;
+
+/**
+ * How many lines were dropped since the worker started, by reason
+ */
+const droppedLines: Record<string, number> = {};
+
+/**
+ * Count lines dropped for the given reason
+ */
+const countDropped = (reason: string, count: number) => {
+  droppedLines[reason] = (droppedLines[reason] ?? 0) + count;
+};
+
+/**
+ * How often to try writing the held lines while the disk is full
+ */
+const DISK_FULL_RETRY_MS = 5000;
+
+/**
+ * Set once the worker is shutting down or draining, so held lines get no later retry
+ */
+let closing = false;
+
+/**
+ * Holds the stream for the errors only log file, opened by the first error written to it
+ */
+let errorStream: fs.WriteStream | null = null;
+
+/**
+ * Name of the errors only log file the error stream writes to
+ */
+let errorFileName = "";
+
+/**
+ * If entries at ERROR and above are also written to the errors only log file
+ */
+let splitErrorLog = false;
+
+/**
+ * Name of the link pointing at the current log file
+ */
+const LATEST_FILE_NAME = "latest.log";
+
+/**
+ * Maximum number of bytes the log files in the base path may use, no limit when null
+ */
+let maxTotalSize: number | null = null;
+
+/**
+ * How often the total size of the log files is checked against the limit
+ */
+const TOTAL_SIZE_CHECK_MS = 60_000;
+
+/**
+ * If a total size check is currently running
+ */
+let enforcingTotalSize = false;
+
+/**
+ * When the next scheduled total size check runs as a unix timestamp in milliseconds
+ */
+let nextTotalSizeCheckAt = 0;
+
+/**
+ * Matches the names of the log files this worker creates, built from the file name template.
+ * Files of earlier processes match too, with each `{pid}` captured
+ */
+let logFilePattern = /^\d{4}-\d{2}-\d{2}\.log$/;
+
+/**
+ * Matches the diagnostic and rejected request files the worker keeps next to the log files
+ */
+const SIDE_FILE_PATTERN = /^(?:diagnostics|rejected)-\d{4}-\d{2}-\d{2}\.log$/;
+
 /**
  * Holds the buffer of log entries waiting to be written
  */
@@ -38,39 +235,575 @@ const FLUSH_MS = 130;
 const BUFFER_FLUSH_COUNT = 300;
 
 /**
- * Holds the timeout for flush
+ * How many bytes of entries can accumulate before we have to flush, so a few large entries
+ * don't wait for the count or the timer
+ */
+const BUFFER_FLUSH_BYTES = 256 * 1024;
+
+/**
+ * How many bytes the entries in the buffer take up, including their newlines
+ */
+let logBufferBytes = 0;
+
+/**
+ * Holds the timeout for flush
+ */
+let flushTimeout: NodeJS.Timeout | null = null;
+
+/**
+ * Clears the pending flush timeout
+ */
+const clearFlushTimeout = () => {
+  if (flushTimeout) {
+    clearTimeout(flushTimeout);
+    flushTimeout = null;
+  }
+};
+
+/**
+ * Sends a message about the worker itself to the logger, which reports errors through onError
+ * or stderr and prints the rest to stderr, keeping the application's stdout clean
+ * @param level If the message is an error or informational
+ * @param message What happened
+ */
+const reportDiagnostic = (
+  level: WorkerDiagnostic["diagnostic"],
+  message: string,
+) => {
+  const diagnostic: WorkerDiagnostic = { diagnostic: level, message };
+  parentPort?.postMessage(diagnostic);
+};
+
+/**
+ * How long repeats of the same kind of error are counted before a summary of them is printed
+ */
+const ERROR_REPORT_WINDOW_MS = 60_000;
+
+/**
+ * Errors currently being aggregated by kind, with how many repeats were held back, the last
+ * message and the timer that prints the summary
+ */
+const errorReports: Map<
+  string,
+  { repeats: number; lastMessage: string; timer: NodeJS.Timeout }
+> = new Map();
+
+/**
+ * If the last write to the diagnostic file failed, so a broken disk reports it once
+ */
+let diagnosticFileFailing = false;
+
+/**
+ * Appends an error to `diagnostics-YYYY-MM-DD.log` so every occurrence is kept even when the
+ * console only shows a summary of them
+ * @param message The error message
+ */
+const recordDiagnostic = (message: string) => {
+  const { year, month, day } = getDateParts(new Date());
+  const filePath = path.join(
+    basePath,
+    `diagnostics-${year}-${month}-${day}.log`,
+  );
+
+  try {
+    fs.appendFileSync(filePath, `[${new Date().toISOString()}] ${message}\n`);
+    diagnosticFileFailing = false;
+  } catch (error) {
+    if (!diagnosticFileFailing) {
+      reportDiagnostic(
+        "error",
+        `Failed to record diagnostic: ${(error as Error).message}`,
+      );
+    }
+    diagnosticFileFailing = true;
+  }
+};
+
+/**
+ * Prints the summary of an aggregated kind of error if any repeats were held back
+ * @param kind What failed
+ * @param parentListening If the parent still handles messages, when it does not the summary
+ * is only recorded in the diagnostic file
+ */
+const endErrorReport = (kind: string, parentListening = true) => {
+  const report = errorReports.get(kind);
+  if (!report) return;
+
+  clearTimeout(report.timer);
+  errorReports.delete(kind);
+
+  if (report.repeats === 0) return;
+
+  const summary = `${kind} repeated ${report.repeats} times in the last ${ERROR_REPORT_WINDOW_MS / 1000}s, last error: ${report.lastMessage}`;
+  if (parentListening) {
+    reportDiagnostic("error", summary);
+  } else {
+    recordDiagnostic(summary);
+  }
+};
+
+/**
+ * Reports an error without flooding stderr, the first error of a kind is printed straight
+ * away and repeats within the window are summarised once it ends. Every occurrence is
+ * recorded in the diagnostic file.
+ * @param kind What failed for example `"Write error"`
+ * @param message The error message
+ */
+const reportError = (kind: string, message: string) => {
+  recordDiagnostic(`${kind}: ${message}`);
+
+  const report = errorReports.get(kind);
+  if (report) {
+    report.repeats++;
+    report.lastMessage = message;
+    return;
+  }
+
+  reportDiagnostic("error", `${kind}: ${message}`);
+  errorReports.set(kind, {
+    repeats: 0,
+    lastMessage: message,
+    timer: setTimeout(() => endErrorReport(kind), ERROR_REPORT_WINDOW_MS).unref(),
+  });
+};
+
+/**
+ * Callback added to the write for errors
+ */
+const onWriteError = (error: Error | null | undefined) => {
+  if (!error) return;
+
+  fileStats.errors++;
+  reportError("Write error", error.message);
+};
+
+/**
+ * Flushes the buffer to the file and resets it
+ */
+const flush = () => {
+  if (logBuffer.length === 0 || !fileStream) return;
+
+  rollOverIfNeeded();
+
+  // a write that failed every retry left the stream destroyed
+  if (fileStream.destroyed && !diskFullBuffer) createStream();
+
+  // context for an error only goes to the errors only log file
+  const entries = logBuffer.filter((x) => !x.errorContext);
+  fileStats.entries += entries.length;
+
+  const payload = entries.map((x) => x.payload).join("\n") + "\n";
+
+  if (entries.length === 0) {
+    // nothing for the log file
+  } else if (diskFullBuffer) {
+    holdWhileDiskFull(payload);
+  } else {
+    writeToFile(payload);
+  }
+
+  if (splitErrorLog) writeErrorEntries(logBuffer);
+
+  logBuffer = [];
+  logBufferBytes = 0;
+  lastFlushAt = Date.now();
+  clearFlushTimeout();
+};
+
+/**
+ * Waits for every pending write to reach the file then fsyncs it
+ * @param callback Called once synced or with the error that stopped it
+ */
+const syncToDisk = (callback: (error: Error | null) => void) => {
+  const stream = fileStream;
+  if (!stream) {
+    callback(null);
+    return;
+  }
+
+  // writes complete in order so this callback runs after all earlier ones
+  stream.write("", (writeError) => {
+    if (writeError) {
+      callback(writeError);
+      return;
+    }
+
+    const fd = (stream as fs.WriteStream & { fd?: number }).fd;
+    if (typeof fd !== "number") {
+      callback(null);
+      return;
+    }
+
+    fs.fsync(fd, callback);
+  });
+};
+
+/**
+ * Writes to the current log file, once every pending write has completed the write ahead log
+ * is reset to what's still only in memory. Failed writes are retried with backoff
+ * @param payload The lines to write
+ * @param attempt How many times this payload has been retried
+ */
+const writeToFile = (payload: string, attempt = 0) => {
+  if (attempt === 0) pendingWrites++;
+
+  fileStream?.write(payload, (error) => {
+    if (
+      error &&
+      !isDiskFull(error) &&
+      !diskFullBuffer &&
+      attempt < writeRetries
+    ) {
+      // a stream that errored is destroyed so the retry opens the file again
+      setTimeout(() => {
+        if (!fileStream || fileStream.destroyed) createStream();
+        writeToFile(payload, attempt + 1);
+      }, WRITE_RETRY_BASE_MS * 2 ** attempt);
+      return;
+    }
+
+    pendingWrites--;
+
+    // later writes fail as the stream is destroyed so they are held too
+    if (isDiskFull(error) || (error && diskFullBuffer)) {
+      holdWhileDiskFull(payload);
+      return;
+    }
+
+    onWriteError(error);
+
+    if (error) {
+      countDropped("writeFailed", payload.split("\n").length - 1);
+    } else {
+      resetWriteAheadLog();
+    }
+  });
+};
+
+/**
+ * If a stream error came from a write, those are handled by the callback of the write
+ * @param error The error emitted by the stream
+ */
+const isWriteError = (error: NodeJS.ErrnoException) =>
+  error.syscall === "write" || error.syscall === "writev";
+
+/**
+ * If the error means there is no space left on the device
+ * @param error The error from the write
+ */
+const isDiskFull = (error: Error | null | undefined) =>
+  (error as NodeJS.ErrnoException | null | undefined)?.code === "ENOSPC";
+
+/**
+ * Holds lines in memory until the disk has space, starting the retries the first time
+ * @param payload The lines that couldn't be written
+ */
+const holdWhileDiskFull = (payload: string) => {
+  if (!diskFullBuffer) {
+    diskFullBuffer = [];
+    diskFullDropped = 0;
+    reportDiagnostic(
+      "error",
+      `Disk is full, holding up to ${DISK_FULL_BUFFER_LINES} lines in memory until space returns`,
+    );
+    setTimeout(retryDiskFull, DISK_FULL_RETRY_MS);
+  }
+
+  diskFullBuffer.push(...payload.slice(0, -1).split("\n"));
+
+  const overflow = diskFullBuffer.length - DISK_FULL_BUFFER_LINES;
+  if (overflow > 0) {
+    diskFullBuffer.splice(0, overflow);
+    diskFullDropped += overflow;
+    countDropped("diskFull", overflow);
+  }
+};
+
+/**
+ * Tries writing the held lines to a new stream, going back to normal writes once they land
+ */
+const retryDiskFull = () => {
+  if (!diskFullBuffer) return;
+
+  if (!fileStream || fileStream.destroyed) createStream();
+
+  const held = diskFullBuffer;
+  diskFullBuffer = [];
+
+  fileStream?.write(held.join("\n") + "\n", (error) => {
+    if (error && closing) {
+      const lost = held.length + (diskFullBuffer?.length ?? 0);
+      diskFullBuffer = null;
+      countDropped("diskFull", lost);
+      reportDiagnostic(
+        "error",
+        `Disk is still full, dropped ${lost} held lines` +
+          (diskFullDropped > 0 ? ` and ${diskFullDropped} before them` : ""),
+      );
+      return;
+    }
+
+    if (error) {
+      diskFullBuffer = held.concat(diskFullBuffer ?? []);
+      setTimeout(retryDiskFull, DISK_FULL_RETRY_MS);
+      return;
+    }
+
+    // lines held while this write was in flight go out before new ones
+    const heldSince = diskFullBuffer ?? [];
+    diskFullBuffer = null;
+    if (heldSince.length > 0) writeToFile(heldSince.join("\n") + "\n");
+
+    reportDiagnostic(
+      "info",
+      `Disk has space again, wrote ${held.length + heldSince.length} held lines` +
+        (diskFullDropped > 0 ? `, ${diskFullDropped} were dropped` : ""),
+    );
+    resetWriteAheadLog();
+  });
+};
+
+/**
+ * Makes a last attempt at writing the lines held while the disk is full, queued before the
+ * stream is ended. Lines that still can't be written are counted as dropped and reported
+ */
+const writeHeldLinesBeforeClosing = () => {
+  closing = true;
+
+  // an empty buffer means a retry is in flight, which drops its lines itself if it fails
+  if (diskFullBuffer && diskFullBuffer.length > 0) retryDiskFull();
+};
+
+/**
+ * Appends an entry to the write ahead log before it is buffered
+ * @param payload The entry
+ */
+const appendToWriteAheadLog = (payload: string) => {
+  if (walFd === null) return;
+
+  try {
+    fs.writeSync(walFd, payload + "\n");
+  } catch (error) {
+    reportError("Write ahead log error", (error as Error).message);
+  }
+};
+
+/**
+ * Truncates the write ahead log once nothing is waiting to be written, keeping the entries
+ * buffered since the last flush
+ */
+const resetWriteAheadLog = () => {
+  if (walFd === null || pendingWrites > 0) return;
+
+  try {
+    fs.ftruncateSync(walFd, 0);
+
+    const entries = logBuffer.filter((x) => !x.errorContext);
+    if (entries.length > 0) {
+      fs.writeSync(walFd, entries.map((x) => x.payload).join("\n") + "\n");
+    }
+  } catch (error) {
+    reportError("Write ahead log error", (error as Error).message);
+  }
+};
+
+/**
+ * If a process with the pid is running, a process owned by another user still counts
+ * @param pid The pid to check
+ */
+const isProcessAlive = (pid: number) => {
+  try {
+    process.kill(pid, 0);
+    return true;
+  } catch (error) {
+    return (error as NodeJS.ErrnoException).code === "EPERM";
+  }
+};
+
+/**
+ * If a write ahead log was left by an owner that is gone. Logs from other hosts are never
+ * taken over since whether their owner is running can't be checked from here
+ * @param fileName Name of the write ahead log
+ */
+const isAbandonedWriteAheadLog = (fileName: string) => {
+  const match = WAL_FILE_PATTERN.exec(fileName);
+  if (!match) return false;
+
+  const [, host, pid, thread] = match;
+  if (host === undefined) return true;
+  if (host !== os.hostname()) return false;
+
+  // another thread of this process may still be using it, the same thread is a reused pid
+  if (Number(pid) === process.pid) return Number(thread) === threadId;
+
+  return !isProcessAlive(Number(pid));
+};
+
+/**
+ * Opens this worker's write ahead log and writes anything left in the logs of processes that
+ * didn't shut down cleanly to the current log file
+ */
+const openWriteAheadLog = () => {
+  try {
+    const walPath = path.join(basePath, WAL_FILE_NAME);
+
+    // an earlier process with the same pid left it, it is not claimed as it stays this worker's
+    // log and its entries are only dropped from it once they are written
+    const leftover = fs.existsSync(walPath)
+      ? fs.readFileSync(walPath, "utf-8")
+      : "";
+
+    const abandoned = fs
+      .readdirSync(basePath)
+      .filter(
+        (fileName) =>
+          fileName !== WAL_FILE_NAME && isAbandonedWriteAheadLog(fileName),
+      );
+
+    walFd = fs.openSync(walPath, "a");
+
+    let remnants = "";
+    if (leftover.length > 0) {
+      remnants = leftover.endsWith("\n") ? leftover : leftover + "\n";
+      if (remnants !== leftover) fs.writeSync(walFd, "\n");
+    }
+
+    const claimedPath = path.join(basePath, CLAIMED_WAL_FILE_NAME);
+
+    for (const fileName of abandoned) {
+      // renaming claims the log, another process starting now can't replay it as well
+      try {
+        fs.renameSync(path.join(basePath, fileName), claimedPath);
+      } catch {
+        continue;
+      }
+
+      const content = fs.readFileSync(claimedPath, "utf-8");
+      if (content.length > 0) {
+        const entries = content.endsWith("\n") ? content : content + "\n";
+        fs.writeSync(walFd, entries);
+        remnants += entries;
+      }
+      fs.rmSync(claimedPath);
+    }
+
+    if (remnants.length > 0) {
+      reportDiagnostic(
+        "info",
+        `Replaying ${Buffer.byteLength(remnants)} bytes from the write ahead log`,
+      );
+      writeToFile(remnants);
+    }
+  } catch (error) {
+    reportError("Write ahead log error", (error as Error).message);
+  }
+};
+
+/**
+ * Closes the write ahead log, removing it when everything in it was written so logs of
+ * processes that shut down cleanly don't pile up
+ */
+const closeWriteAheadLog = () => {
+  if (walFd === null) return;
+
+  try {
+    const empty = fs.fstatSync(walFd).size === 0;
+    fs.closeSync(walFd);
+    walFd = null;
+
+    if (empty) fs.rmSync(path.join(basePath, WAL_FILE_NAME));
+  } catch (error) {
+    reportError("Write ahead log error", (error as Error).message);
+  }
+};
+
+/**
+ * Switches to a new log file when the date (or hour, depending on the template) in the file
+ * name has changed, printing a summary of the file that was closed
+ */
+const rollOverIfNeeded = () => {
+  if (!fileStream || getLogFileName() === currentFileName) return;
+
+  const closedStream = fileStream;
+  const closedFilePath = path.join(basePath, currentFileName);
+  const { entries, errors } = fileStats;
+
+  rotationIndex = 0;
+  createStream();
+  closeErrorStream();
+
+  closedStream.end(() => {
+    reportDiagnostic(
+      "info",
+      `Rolled over log file ${closedFilePath}: entries=${entries}, bytes=${closedStream.bytesWritten}, errors=${errors}`,
+    );
+
+    // only safe once the closed file has had its last write
+    runFileMaintenance();
+  });
+};
+
+/**
+ * Gets the name of the errors only log file, the current log file name without its rotation
+ * suffix and with `.error` before the extension
  */
-let flushTimeout: NodeJS.Timeout | null = null;
+const getErrorLogFileName = (): string => {
+  const fileName = getLogFileName(0);
+  const extension = getTemplateExtension();
 
-/**
- * Clears the pending flush timeout
- */
-const clearFlushTimeout = () => {
-  if (flushTimeout) {
-    clearTimeout(flushTimeout);
-    flushTimeout = null;
-  }
+  const name = fileName.slice(0, fileName.length - extension.length);
+
+  return `${name}.error${extension}`;
 };
 
 /**
- * Callback added to the write for errors
+ * Writes the entries at ERROR and above, with the context sent ahead of them, to the errors only
+ * log file, opening it if needed
+ * @param entries The entries being flushed
  */
-const onWriteError = (error: Error | null | undefined) => {
-  if (error) process.stderr.write(`Write error: ${error?.message}`);
+const writeErrorEntries = (entries: RequestLog[]) => {
+  const errors = entries.filter(
+    (x) =>
+      x.errorContext ||
+      (x.level !== undefined &&
+        LOG_LEVEL_PRIORITY[x.level] >= LOG_LEVEL_PRIORITY[LOG_LEVEL.ERROR]),
+  );
+  if (errors.length === 0) return;
+
+  const fileName = getErrorLogFileName();
+  if (!errorStream || errorStream.destroyed || fileName !== errorFileName) {
+    closeErrorStream();
+
+    const filePath = path.join(basePath, fileName);
+    errorFileName = fileName;
+    errorStream = fs.createWriteStream(filePath, { flags: "a" });
+    errorStream.once("open", () => applyFileOwner(filePath));
+    errorStream.on("error", (err) => {
+      if (isWriteError(err)) return;
+      reportError("Error log stream error", err.message);
+    });
+  }
+
+  errorStream.write(
+    errors.map((x) => x.payload).join("\n") + "\n",
+    onWriteError,
+  );
 };
 
 /**
- * Flushes the buffer to the file and resets it
+ * Ends the errors only log file stream, the next error opens it again
+ * @param callback Called once the stream has finished
  */
-const flush = () => {
-  if (logBuffer.length === 0 || !fileStream) return;
-
-  const payload = logBuffer.map((x) => x.payload).join("\n") + "\n";
+const closeErrorStream = (callback: () => void = () => {}) => {
+  const stream = errorStream;
+  errorStream = null;
 
-  fileStream.write(payload, onWriteError);
+  if (!stream || stream.destroyed) {
+    callback();
+    return;
+  }
 
-  logBuffer = [];
-  clearFlushTimeout();
+  stream.end(callback);
 };
 
 /**
@@ -94,15 +827,128 @@ const sendResponse = (response: LogResponse) => {
   parentPort?.postMessage(response);
 };
 
+/**
+ * How many characters of a rejected request are kept in the rejection log
+ */
+const REJECTED_PREVIEW_LENGTH = 200;
+
+/**
+ * Appends a rejected request with the reason and a preview of it to `rejected-YYYY-MM-DD.log`
+ * so bugs in what is sent to the worker can be found later
+ * @param reason Why the request was rejected
+ * @param request The rejected request
+ */
+const recordRejected = (reason: string, request: unknown) => {
+  const { year, month, day } = getDateParts(new Date());
+  const filePath = path.join(basePath, `rejected-${year}-${month}-${day}.log`);
+
+  let preview: string;
+  try {
+    preview = JSON.stringify(request) ?? String(request);
+  } catch {
+    preview = String(request);
+  }
+
+  // written straight away so a shutdown right after a rejection cannot lose it
+  try {
+    fs.appendFileSync(
+      filePath,
+      `[${new Date().toISOString()}] ${reason}: ${preview.slice(0, REJECTED_PREVIEW_LENGTH)}\n`,
+    );
+  } catch (error) {
+    reportDiagnostic(
+      "error",
+      `Failed to record rejection: ${(error as Error).message}`,
+    );
+  }
+};
+
+/**
+ * Checks a request has the shape the protocol describes before it is handled
+ * @param request The decoded request
+ * @returns Why the request is invalid, or null if it is valid
+ */
+const getRequestProblem = (request: unknown): string | null => {
+  if (typeof request !== "object" || request === null) {
+    return "request is not an object";
+  }
+
+  const { id, method, level, durability, errorContext, payload } =
+    request as Record<string, unknown>;
+
+  if (typeof method !== "number" || !VALID_METHODS.has(method)) {
+    return `unknown method ${String(method)}`;
+  }
+  if (typeof payload !== "string") {
+    return `payload must be a string, got ${typeof payload}`;
+  }
+  if (
+    (method === METHOD.LOG || level !== undefined) &&
+    (typeof level !== "number" || !VALID_LOG_LEVELS.has(level))
+  ) {
+    return `unknown level ${String(level)}`;
+  }
+  if (
+    durability !== undefined &&
+    (typeof durability !== "number" || !VALID_DURABILITIES.has(durability))
+  ) {
+    return `unknown durability ${String(durability)}`;
+  }
+  if (errorContext !== undefined && typeof errorContext !== "boolean") {
+    return `errorContext must be a boolean, got ${typeof errorContext}`;
+  }
+  if (
+    method !== METHOD.LOG &&
+    method !== METHOD.DRAIN &&
+    typeof id !== "number"
+  ) {
+    return "missing request id";
+  }
+
+  return null;
+};
+
+/**
+ * Reports and records a request that failed validation, answering it if it can be answered
+ * @param reason Why the request was rejected
+ * @param request The rejected request
+ */
+const rejectRequest = (reason: string, request: unknown) => {
+  reportError("Rejected request", reason);
+  recordRejected(reason, request);
+
+  const { id, method, level } = (request ?? {}) as Partial<RequestLog>;
+  if (typeof id === "number") {
+    sendResponse({
+      id,
+      level: level!,
+      method: method!,
+      success: false,
+    });
+  }
+};
+
 /**
  * Handle the request decoded
  */
 const requestHandler = (request: RequestLog) => {
+  const problem = getRequestProblem(request);
+  if (problem !== null) {
+    rejectRequest(problem, request);
+    return;
+  }
+
   switch (request.method) {
     case METHOD.LOG: {
+      // only kept for a crash if it would have been written to the log file
+      if (!request.errorContext) appendToWriteAheadLog(request.payload);
       logBuffer.push(request);
+      logBufferBytes += Buffer.byteLength(request.payload) + 1;
 
-      if (logBuffer.length >= BUFFER_FLUSH_COUNT) {
+      if (
+        logBuffer.length >= BUFFER_FLUSH_COUNT ||
+        logBufferBytes >= BUFFER_FLUSH_BYTES
+      ) {
         flush();
       } else {
         startFlush();
@@ -110,10 +956,26 @@ const requestHandler = (request: RequestLog) => {
       break;
     }
 
-    case METHOD.FLUSH:
+    case METHOD.FLUSH: {
       flush();
 
-      if (fileStream?.writableNeedDrain) {
+      const durability = request.durability ?? DURABILITY.OS;
+
+      if (durability === DURABILITY.FSYNC) {
+        syncToDisk((error) => {
+          if (error) onWriteError(error);
+
+          sendResponse({
+            id: request.id!,
+            level: request.level!,
+            method: request.method,
+            success: !error,
+          });
+        });
+      } else if (
+        durability === DURABILITY.OS &&
+        fileStream?.writableNeedDrain
+      ) {
         fileStream.once("drain", () => {
           sendResponse({
             id: request.id!,
@@ -131,13 +993,16 @@ const requestHandler = (request: RequestLog) => {
         });
       }
       break;
+    }
 
     case METHOD.RELOAD:
       flush();
+      closeErrorStream();
 
       fileStream?.end(() => {
         fileStream = null;
         createStream();
+        runFileMaintenance();
 
         sendResponse({
           id: request.id!,
@@ -148,46 +1013,461 @@ const requestHandler = (request: RequestLog) => {
       });
       return;
 
-    case METHOD.SHUTDOWN:
+    case METHOD.ROTATE:
       flush();
 
       fileStream?.end(() => {
+        fileStream = null;
+        rotationIndex = getNextRotationIndex();
+        createStream();
+        runFileMaintenance();
+
         sendResponse({
           id: request.id!,
           level: request.level!,
           method: request.method,
           success: true,
         });
+      });
+      return;
 
-        setImmediate(() => {
-          process.exit(EXIT_SUCCESS);
+    case METHOD.STATUS: {
+      // taken now, the retention plan is answered once the base path has been read
+      const status = {
+        filePath: fileStream ? path.join(basePath, currentFileName) : null,
+        bytesWritten: fileStream?.bytesWritten ?? 0,
+        bufferedEntries: logBuffer.length,
+        lastFlushAt,
+        dropped: { ...droppedLines },
+      };
+
+      getRetentionPlan(currentFileName).then((retention) => {
+        sendResponse({
+          id: request.id!,
+          level: request.level!,
+          method: request.method,
+          success: true,
+          status: { ...status, retention },
         });
       });
+      break;
+    }
+
+    case METHOD.DRAIN: {
+      const drained = request.drained;
+      flush();
+      writeHeldLinesBeforeClosing();
+
+      // the parent is exiting and will not print them, so pending summaries go to the file
+      for (const kind of [...errorReports.keys()]) {
+        endErrorReport(kind, false);
+      }
+
+      // the parent is blocked waiting so only the writes are finished, nothing is answered
+      closeErrorStream(() => {
+        const done = () => {
+          closeWriteAheadLog();
+
+          if (!drained) return;
+          Atomics.store(drained, 0, 1);
+          Atomics.notify(drained, 0);
+        };
+
+        if (fileStream) {
+          fileStream.end(done);
+        } else {
+          done();
+        }
+      });
       return;
+    }
+
+    case METHOD.SHUTDOWN:
+      flush();
+      writeHeldLinesBeforeClosing();
 
-    default:
-      process.stderr.write(`Unhandled request method: ${request.method}\n`);
-      sendResponse({
-        id: request.id!,
-        level: request.level!,
-        method: request.method,
-        success: false,
+      // the window timers do not keep the worker alive, so summaries still pending are printed now
+      for (const kind of [...errorReports.keys()]) {
+        endErrorReport(kind);
+      }
+
+      closeErrorStream(() => {
+        fileStream?.end(async () => {
+          // an archive cut off by exiting would be compressed again on the next start
+          await compression;
+          closeWriteAheadLog();
+
+          sendResponse({
+            id: request.id!,
+            level: request.level!,
+            method: request.method,
+            success: true,
+          });
+
+          setImmediate(() => {
+            process.exit(EXIT_SUCCESS);
+          });
+        });
       });
-      break;
+      return;
+  }
+};
+
+/**
+ * Splits a date into the zero padded parts used in file names, in the configured time zone
+ * or local time when none is set
+ * @param date The date to split
+ */
+const getDateParts = (date: Date) => {
+  if (!timeZone) {
+    return {
+      year: String(date.getFullYear()),
+      month: String(date.getMonth() + 1).padStart(2, "0"),
+      day: String(date.getDate()).padStart(2, "0"),
+      hour: String(date.getHours()).padStart(2, "0"),
+    };
+  }
+
+  const parts = new Intl.DateTimeFormat("en-US", {
+    timeZone,
+    year: "numeric",
+    month: "2-digit",
+    day: "2-digit",
+    hour: "2-digit",
+    hourCycle: "h23",
+  }).formatToParts(date);
+
+  const get = (type: Intl.DateTimeFormatPartTypes) =>
+    parts.find((part) => part.type === type)?.value ?? "";
+
+  return {
+    year: get("year"),
+    month: get("month"),
+    day: get("day"),
+    hour: get("hour"),
+  };
+};
+
+/**
+ * Gets the extension of the file name template, suffixes go before it. A template ending in a
+ * token such as `app.{date}` has no extension
+ */
+const getTemplateExtension = (): string => {
+  const extension = path.extname(fileNameTemplate);
+  return /\{(?:date|hour|host|pid)\}/.test(extension) ? "" : extension;
+};
+
+/**
+ * Generates a log filename from the file name template and current date
+ * @param rotation Which on demand rotation of the file to name, 0 for the first file
+ * @returns Filename, by default in format YYYY-MM-DD.log or YYYY-MM-DD.N.log once rotated
+ */
+const getLogFileName = (rotation = rotationIndex): string => {
+  const { year, month, day, hour } = getDateParts(new Date());
+  const extension = getTemplateExtension();
+
+  const name = fileNameTemplate
+    .slice(0, fileNameTemplate.length - extension.length)
+    .replaceAll("{date}", `${year}-${month}-${day}`)
+    .replaceAll("{hour}", hour)
+    .replaceAll("{host}", os.hostname())
+    .replaceAll("{pid}", String(process.pid));
+
+  return rotation > 0
+    ? `${name}.${rotation}${extension}`
+    : `${name}${extension}`;
+};
+
+/**
+ * Finds the next rotation suffix that doesn't belong to an existing file or archive
+ */
+const getNextRotationIndex = (): number => {
+  const isTaken = (index: number) => {
+    const filePath = path.join(basePath, getLogFileName(index));
+    return fs.existsSync(filePath) || fs.existsSync(`${filePath}.gz`);
+  };
+
+  let next = rotationIndex + 1;
+  while (isTaken(next)) {
+    next++;
+  }
+  return next;
+};
+
+/**
+ * Escapes a string so it can be used literally inside a regular expression
+ */
+const escapeRegExp = (value: string): string =>
+  value.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+
+/**
+ * Builds a pattern matching any file name the template can produce for this host, `{pid}`
+ * matches any pid and is captured so files of other processes can be told apart
+ * @returns Pattern for the log file names
+ */
+const getLogFilePattern = (): RegExp => {
+  const extension = getTemplateExtension();
+
+  const source = fileNameTemplate
+    .slice(0, fileNameTemplate.length - extension.length)
+    .split(/(\{(?:date|hour|host|pid)\})/)
+    .map((part) => {
+      switch (part) {
+        case "{date}":
+          return "\\d{4}-\\d{2}-\\d{2}";
+        case "{hour}":
+          return "\\d{2}";
+        case "{host}":
+          return escapeRegExp(os.hostname());
+        case "{pid}":
+          return "(\\d+)";
+        default:
+          return escapeRegExp(part);
+      }
+    })
+    .join("");
+
+  return new RegExp(
+    `^${source}(?:\\.error)?(?:\\.\\d+)?${escapeRegExp(extension)}$`,
+  );
+};
+
+/**
+ * Assigns a created file to the configured owner, needs root or CAP_CHOWN
+ * @param filePath Path to the created file
+ */
+const applyFileOwner = async (filePath: string) => {
+  if (!fileOwner) return;
+
+  try {
+    await fs.promises.chown(filePath, fileOwner.uid, fileOwner.gid);
+  } catch (error) {
+    reportDiagnostic(
+      "error",
+      `Failed to change owner of ${filePath}: ${(error as Error).message}`,
+    );
+  }
+};
+
+/**
+ * Opens the archive for a log file without overwriting an existing one, when `<file>.gz` is
+ * taken the next free rotation suffix is used instead, for example `2026-01-13.2.log.gz`
+ * @param filePath Path to the log file
+ */
+const openArchive = async (filePath: string) => {
+  const fileName = path.basename(filePath);
+  const extension = getTemplateExtension();
+  const name = fileName
+    .slice(0, fileName.length - extension.length)
+    .replace(/\.\d+$/, "");
+
+  for (let index = 0; ; index++) {
+    const archivePath =
+      index === 0
+        ? `${filePath}.gz`
+        : path.join(path.dirname(filePath), `${name}.${index}${extension}.gz`);
+
+    try {
+      return { archivePath, handle: await fs.promises.open(archivePath, "wx") };
+    } catch (error) {
+      if ((error as NodeJS.ErrnoException).code !== "EEXIST") throw error;
+    }
+  }
+};
+
+/**
+ * Compresses a log file to `<file>.gz` and removes the original
+ * @param filePath Path to the log file
+ */
+const compressFile = async (filePath: string) => {
+  const { archivePath, handle } = await openArchive(filePath);
+
+  try {
+    await pipeline(
+      fs.createReadStream(filePath),
+      zlib.createGzip(),
+      handle.createWriteStream(),
+    );
+  } catch (error) {
+    await fs.promises.rm(archivePath, { force: true });
+    throw error;
+  }
+
+  await applyFileOwner(archivePath);
+
+  await fs.promises.unlink(filePath);
+};
+
+/**
+ * If a log file belongs to another process that is still running, so it may still be written to
+ * @param fileName Name of the log file
+ */
+const isInUseByOtherProcess = (fileName: string) => {
+  const pids = logFilePattern.exec(fileName)?.slice(1) ?? [];
+
+  return pids.some(
+    (pid) => Number(pid) !== process.pid && isProcessAlive(Number(pid)),
+  );
+};
+
+/**
+ * Compresses every log file in the base path except the ones currently being written to, by
+ * this worker or another running process
+ * @param currentFileName Name of the active log file
+ */
+const compressOldLogFiles = async (currentFileName: string) => {
+  const fileNames = await fs.promises.readdir(basePath);
+
+  for (const fileName of fileNames) {
+    if (
+      fileName === currentFileName ||
+      fileName === getErrorLogFileName() ||
+      !logFilePattern.test(fileName) ||
+      isInUseByOtherProcess(fileName)
+    ) {
+      continue;
+    }
+
+    try {
+      await compressFile(path.join(basePath, fileName));
+    } catch (error) {
+      reportDiagnostic(
+        "error",
+        `Failed to compress ${fileName}: ${(error as Error).message}`,
+      );
+    }
+  }
+};
+
+/**
+ * Points `latest.log` at the current log file, the link is created under a temporary name and
+ * renamed over the old one so readers never see it missing. Windows gets a hard link instead as
+ * creating symlinks there needs elevated privileges, it is the same file so it stays current
+ * @param currentFileName Name of the active log file
+ */
+const updateLatestLink = async (currentFileName: string) => {
+  const latestPath = path.join(basePath, LATEST_FILE_NAME);
+  const tempPath = `${latestPath}.${process.pid}.${threadId}.tmp`;
+  await fs.promises.rm(tempPath, { force: true });
+
+  if (process.platform === "win32") {
+    await fs.promises.link(path.join(basePath, currentFileName), tempPath);
+  } else {
+    await fs.promises.symlink(currentFileName, tempPath);
+  }
+
+  await fs.promises.rename(tempPath, latestPath);
+};
+
+/**
+ * Checks if a file in the base path is a log file this worker manages, compressed or not
+ * @param fileName Name of the file
+ */
+const isManagedLogFile = (fileName: string): boolean =>
+  logFilePattern.test(fileName) ||
+  (fileName.endsWith(".gz") && logFilePattern.test(fileName.slice(0, -3)));
+
+/**
+ * Checks if a file in the base path counts towards the total size limit, the log files along
+ * with the diagnostic, rejected request and write ahead log files
+ * @param fileName Name of the file
+ */
+const countsTowardsTotalSize = (fileName: string): boolean =>
+  isManagedLogFile(fileName) ||
+  SIDE_FILE_PATTERN.test(fileName) ||
+  WAL_FILE_PATTERN.test(fileName);
+
+/**
+ * Works out which files to delete, oldest first, to bring the total size under the base path
+ * within the limit. The files currently being written to, by this worker or another running
+ * process, and the write ahead logs are never deleted
+ * @param currentFileName Name of the active log file
+ * @param limit Maximum number of bytes
+ */
+const findFilesOverTotalSize = async (
+  currentFileName: string,
+  limit: number,
+) => {
+  const fileNames = (await fs.promises.readdir(basePath)).filter(
+    countsTowardsTotalSize,
+  );
+
+  const files: { name: string; size: number; mtimeMs: number }[] = [];
+  for (const name of fileNames) {
+    // files can disappear while we look at them e.g. when compressed
+    const stat = await fs.promises
+      .stat(path.join(basePath, name))
+      .catch(() => null);
+
+    if (stat) files.push({ name, size: stat.size, mtimeMs: stat.mtimeMs });
+  }
+
+  let totalSize = files.reduce((total, file) => total + file.size, 0);
+  files.sort((a, b) => a.mtimeMs - b.mtimeMs);
+
+  const overLimit: { name: string; size: number }[] = [];
+  for (const file of files) {
+    if (totalSize <= limit) break;
+    if (
+      file.name === currentFileName ||
+      file.name === getErrorLogFileName() ||
+      WAL_FILE_PATTERN.test(file.name) ||
+      isInUseByOtherProcess(file.name)
+    ) {
+      continue;
+    }
+
+    overLimit.push(file);
+    totalSize -= file.size;
+  }
+
+  return overLimit;
+};
+
+/**
+ * Deletes the oldest files until the total size under the base path is within the limit
+ * @param currentFileName Name of the active log file
+ */
+const enforceMaxTotalSize = async (currentFileName: string) => {
+  const limit = maxTotalSize;
+  if (limit === null || enforcingTotalSize) return;
+  enforcingTotalSize = true;
+
+  try {
+    for (const file of await findFilesOverTotalSize(currentFileName, limit)) {
+      await fs.promises.rm(path.join(basePath, file.name), { force: true });
+    }
+  } finally {
+    enforcingTotalSize = false;
   }
 };
 
 /**
- * Generates a log filename based on current date
- * @returns Filename in format YYYY-MM-DD.log
+ * Works out what the next scheduled total size check will delete without deleting anything,
+ * null when there is no limit or the base path can't be read
+ * @param currentFileName Name of the active log file
  */
-const getLogFileName = (): string => {
-  const date = new Date();
-  const year = date.getFullYear();
-  const month = String(date.getMonth() + 1).padStart(2, "0");
-  const day = String(date.getDate()).padStart(2, "0");
+const getRetentionPlan = async (
+  currentFileName: string,
+): Promise<RetentionPlan | null> => {
+  const limit = maxTotalSize;
+  if (limit === null) return null;
+
+  try {
+    const files = await findFilesOverTotalSize(currentFileName, limit);
 
-  return `${year}-${month}-${day}.log`;
+    return {
+      nextSweepAt: nextTotalSizeCheckAt,
+      candidates: files.map((file) => file.name),
+      candidateBytes: files.reduce((total, file) => total + file.size, 0),
+    };
+  } catch (error) {
+    reportDiagnostic(
+      "error",
+      `Failed to check upcoming deletions: ${(error as Error).message}`,
+    );
+    return null;
+  }
 };
 
 /**
@@ -200,13 +1480,78 @@ const createStream = () => {
   const fileName = getLogFileName();
   const filePath = path.join(basePath, fileName);
 
+  currentFileName = fileName;
+  fileStats = { entries: 0, errors: 0 };
+
   fileStream = fs.createWriteStream(filePath, { flags: "a" });
 
+  fileStream.once("open", () => {
+    applyFileOwner(filePath);
+
+    if (linkLatest) {
+      latestLinkUpdate = latestLinkUpdate
+        .then(() => updateLatestLink(fileName))
+        .catch((error) => {
+          reportDiagnostic(
+            "error",
+            `Failed to update latest link: ${error?.message}`,
+          );
+        });
+    }
+  });
+
   fileStream.on("error", (err) => {
-    console.error(`Stream error: ${err.message}`);
+    // already retried and reported by the writes, only opening the file is reported here
+    if (isDiskFull(err) || isWriteError(err)) return;
+
+    reportError("Stream error", err.message);
+  });
+};
+
+/**
+ * Compresses old log files and enforces the total size limit, run after a new log file is started
+ */
+const runFileMaintenance = () => {
+  if (compressOldLogs) {
+    // runs one at a time so two passes never compress the same file
+    compression = compression
+      .then(() => compressOldLogFiles(currentFileName))
+      .catch((error) => {
+        reportDiagnostic("error", `Compression error: ${error?.message}`);
+      });
+  }
+
+  checkTotalSize();
+};
+
+/**
+ * Runs the total size limit against the current log file, reporting any failure
+ */
+const checkTotalSize = () => {
+  if (!fileStream) return;
+
+  enforceMaxTotalSize(currentFileName).catch((error) => {
+    reportDiagnostic(
+      "error",
+      `Failed to enforce max total size: ${error?.message}`,
+    );
   });
 };
 
+/**
+ * Handle a batch of requests sent by the parent
+ */
+const batchHandler = (requests: RequestLog[]) => {
+  if (!Array.isArray(requests)) {
+    rejectRequest("batch is not an array", requests);
+    return;
+  }
+
+  for (let i = 0, len = requests.length; i < len; i++) {
+    requestHandler(requests[i] as RequestLog);
+  }
+};
+
 /**
  * Main entry point - NOW LAZY, runs only when first message received
  */
@@ -221,20 +1566,49 @@ function main() {
       process.exit();
     }
 
+    compressOldLogs = process.env["COMPRESS_OLD_LOGS"] === "true";
+    fileNameTemplate = process.env["FILE_NAME_TEMPLATE"] ?? fileNameTemplate;
+    logFilePattern = getLogFilePattern();
+    timeZone = process.env["TIME_ZONE"] || undefined;
+    linkLatest = process.env["LINK_LATEST"] === "true";
+
+    const maxTotalSizeEnv = process.env["MAX_TOTAL_SIZE"];
+    if (maxTotalSizeEnv) {
+      maxTotalSize = Number(maxTotalSizeEnv);
+      nextTotalSizeCheckAt = Date.now() + TOTAL_SIZE_CHECK_MS;
+      setInterval(() => {
+        nextTotalSizeCheckAt = Date.now() + TOTAL_SIZE_CHECK_MS;
+        checkTotalSize();
+      }, TOTAL_SIZE_CHECK_MS).unref();
+    }
+
+    const ownerUid = process.env["FILE_OWNER_UID"];
+    const ownerGid = process.env["FILE_OWNER_GID"];
+    if (ownerUid && ownerGid) {
+      fileOwner = { uid: Number(ownerUid), gid: Number(ownerGid) };
+    }
+
     await fs.promises.mkdir(basePath, { recursive: true });
+    await applyFileOwner(basePath);
     createStream();
+    runFileMaintenance();
 
-    // Handle the first batch of requests
-    for (let i = 0, len = initRequests.length; i < len; i++) {
-      requestHandler(initRequests[i] as RequestLog);
+    splitErrorLog = process.env["SPLIT_ERROR_LOG"] === "true";
+
+    const retries = Number(process.env["WRITE_RETRIES"]);
+    if (Number.isInteger(retries) && retries >= 0) {
+      writeRetries = retries;
+    }
+
+    if (process.env["WRITE_AHEAD_LOG"] === "true") {
+      openWriteAheadLog();
     }
 
+    // Handle the first batch of requests
+    batchHandler(initRequests);
+
     // Setup ongoing message handler
-    parentPort?.on("message", (requests: RequestLog[]) => {
-      for (let i = 0, len = requests.length; i < len; i++) {
-        requestHandler(requests[i] as RequestLog);
-      }
-    });
+    parentPort?.on("message", batchHandler);
   });
 }
 
@@ -0,0 +1,83 @@
+/**
+ * Test that collapseRepeats keeps identical consecutive entries up to the threshold and
+ * summarises the rest once a different entry arrives, on the console and in the log file
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./collapse_repeats_test";
+
+if (process.argv[2] === "child") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    basePath,
+    collapseRepeats: { threshold: 2 },
+  });
+
+  for (let i = 0; i < 5; i++) {
+    logger.warn("connection refused");
+  }
+  logger.info("connected");
+  logger.info("connected again");
+
+  await logger.flush();
+  await logger.shutdown();
+} else {
+  main();
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const child = spawnSync(process.execPath, [process.argv[1], "child"], {
+      encoding: "utf-8",
+      env: { ...process.env, NO_COLOR: "1" },
+    });
+    if (child.status !== 0) {
+      throw new Error(`Child exited with ${child.status}: ${child.stderr}`);
+    }
+
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+
+    // the summary is logged at the level of the collapsed entry
+    const expected = [
+      "[WARN]: connection refused",
+      "[WARN]: connection refused",
+      "[WARN]: Last message repeated 3 times",
+      "[INFO]: connected",
+      "[INFO]: connected again",
+    ];
+
+    for (const [name, output] of [
+      ["Console", child.stdout + child.stderr],
+      ["Log file", content],
+    ]) {
+      for (const text of expected) {
+        if (!output.includes(text)) {
+          throw new Error(`${name} is missing "${text}": ${output}`);
+        }
+      }
+
+      const repeats = output.split("connection refused").length - 1;
+      if (repeats !== 2) {
+        throw new Error(`${name} has the repeated entry ${repeats} times`);
+      }
+      console.log(`✓ ${name}: 2 repeats kept and 3 summarised`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
@@ -0,0 +1,109 @@
+/**
+ * Test that rotated files are compressed and an existing archive is never overwritten, and that
+ * files of earlier processes are compressed while those of running ones are left alone
+ */
+
+import { Logger } from "../dist/index.js";
+import fs from "fs/promises";
+import path from "path";
+import zlib from "zlib";
+
+const basePath = "./compression_test";
+
+/**
+ * Logs one entry, rotates, logs another and shuts down, like one run of an app
+ * @param {string} run Name of the run added to each entry
+ */
+async function logRun(run) {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    compressOldLogFiles: true,
+    basePath,
+  });
+
+  logger.info(`${run} before rotate`);
+  await logger.rotate();
+  logger.info(`${run} after rotate`);
+  await logger.flush();
+  await logger.shutdown();
+}
+
+async function main() {
+  await fs.rm(basePath, { recursive: true, force: true });
+
+  try {
+    await logRun("run1");
+    await logRun("run2");
+
+    const fileNames = await fs.readdir(basePath);
+    console.log(`✓ Files: ${fileNames.join(", ")}`);
+
+    let content = "";
+    for (const fileName of fileNames) {
+      const data = await fs.readFile(path.join(basePath, fileName));
+      content += fileName.endsWith(".gz")
+        ? zlib.gunzipSync(data).toString()
+        : data.toString();
+    }
+
+    for (const entry of [
+      "run1 before rotate",
+      "run1 after rotate",
+      "run2 before rotate",
+      "run2 after rotate",
+    ]) {
+      if (!content.includes(entry)) {
+        throw new Error(`Missing entry "${entry}"`);
+      }
+      console.log(`✓ Contains ${entry}`);
+    }
+
+    const archives = fileNames.filter((fileName) => fileName.endsWith(".gz"));
+    if (archives.length < 2) {
+      throw new Error(`Expected at least 2 archives, got ${archives.length}`);
+    }
+    console.log(`✓ Archives kept: ${archives.length}`);
+
+    await fs.rm(basePath, { recursive: true, force: true });
+    await fs.mkdir(basePath, { recursive: true });
+
+    // no pid is this high, while the parent is running
+    const deadPid = 99999999;
+    const livePid = process.ppid;
+    for (const pid of [deadPid, livePid]) {
+      await fs.writeFile(path.join(basePath, `app-2020-01-01-${pid}.log`), "old");
+    }
+
+    const logger = new Logger({
+      saveToLogFiles: true,
+      outputToConsole: false,
+      compressOldLogFiles: true,
+      fileNameTemplate: "app-{date}-{pid}.log",
+      basePath,
+    });
+    logger.info("current entry");
+    await logger.flush();
+    await logger.shutdown();
+
+    const pidFileNames = await fs.readdir(basePath);
+    if (!pidFileNames.includes(`app-2020-01-01-${deadPid}.log.gz`)) {
+      throw new Error(`File of an earlier process not compressed: ${pidFileNames.join(", ")}`);
+    }
+    console.log("✓ File of an earlier process compressed");
+
+    if (!pidFileNames.includes(`app-2020-01-01-${livePid}.log`)) {
+      throw new Error(`File of a running process compressed: ${pidFileNames.join(", ")}`);
+    }
+    console.log("✓ File of a running process left alone");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
@@ -0,0 +1,74 @@
+/**
+ * Test that custom levels are logged, filtered by priority and that unknown names are rejected
+ */
+
+import { Logger } from "../dist/index.js";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./custom_levels_test";
+
+async function main() {
+  await fs.rm(basePath, { recursive: true, force: true });
+
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    minLevel: "AUDIT",
+    customLevels: {
+      TRACE: { priority: 5 },
+      AUDIT: { priority: 35 },
+    },
+  });
+
+  try {
+    logger.custom("AUDIT", "audited");
+    logger.custom("TRACE", "below the minimum");
+    logger.warn("also below the minimum");
+    logger.error("above the minimum");
+
+    let threw = false;
+    try {
+      logger.custom("AUDTI", "misspelled");
+    } catch (error) {
+      threw = error.message === "Unknown log level: AUDTI";
+    }
+    if (!threw) {
+      throw new Error("custom() with an unknown level did not throw");
+    }
+    console.log("✓ Unknown level rejected");
+
+    await logger.flush();
+    await logger.shutdown();
+
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+
+    for (const text of ["[AUDIT]: audited", "[ERROR]: above the minimum"]) {
+      if (!content.includes(text)) {
+        throw new Error(`Missing "${text}"`);
+      }
+      console.log(`✓ Contains ${text}`);
+    }
+
+    for (const text of ["below the minimum", "misspelled"]) {
+      if (content.includes(text)) {
+        throw new Error(`Should not contain "${text}"`);
+      }
+    }
+    console.log("✓ Entries below the minimum and unknown levels not written");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
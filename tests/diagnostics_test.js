@@ -0,0 +1,96 @@
+/**
+ * Test that the worker's own messages go to stderr and stdout only carries log entries
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+
+const basePath = "./diagnostics_test";
+
+if (process.argv[2] === "crash") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    writeAheadLog: true,
+    basePath,
+  });
+
+  await logger.status();
+  logger.info("left in the write ahead log");
+
+  // killed once the entry reached the write ahead log but before the worker flushed it
+  setInterval(async () => {
+    for (const fileName of await fs.readdir(basePath)) {
+      const stat = await fs.stat(`${basePath}/${fileName}`);
+      if (fileName.endsWith(".wal") && stat.size > 0) {
+        process.kill(process.pid, "SIGKILL");
+      }
+    }
+  }, 5);
+} else if (process.argv[2] === "restart") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    writeAheadLog: true,
+    basePath,
+    consoleFormat: process.argv[3],
+  });
+
+  logger.info("after restart");
+  await logger.flush();
+  await logger.shutdown();
+} else {
+  main();
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    for (const format of ["text", "json"]) {
+      await fs.rm(basePath, { recursive: true, force: true });
+
+      spawnSync(process.execPath, [process.argv[1], "crash"]);
+      const restart = spawnSync(
+        process.execPath,
+        [process.argv[1], "restart", format],
+        { encoding: "utf-8", env: { ...process.env, NO_COLOR: "1" } },
+      );
+
+      const stdout = restart.stdout.trim().split("\n");
+      const stderr = restart.stderr.trim().split("\n");
+
+      if (format === "text") {
+        if (stdout.length !== 1 || !stdout[0].endsWith("[INFO]: after restart")) {
+          throw new Error(`stdout has more than the entry: ${restart.stdout}`);
+        }
+        if (!restart.stderr.includes("Replaying")) {
+          throw new Error(`Replay not reported on stderr: ${restart.stderr}`);
+        }
+      } else {
+        const entries = stdout.map((line) => JSON.parse(line));
+        const diagnostics = stderr.map((line) => JSON.parse(line));
+
+        if (entries.length !== 1 || entries[0].msg !== "after restart") {
+          throw new Error(`stdout has more than the entry: ${restart.stdout}`);
+        }
+        if (
+          !diagnostics.some(
+            (line) => line.source === "logger" && line.msg.includes("Replaying"),
+          )
+        ) {
+          throw new Error(`Replay not reported as JSON: ${restart.stderr}`);
+        }
+      }
+
+      console.log(`✓ ${format}: stdout only has the entry, replay reported on stderr`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
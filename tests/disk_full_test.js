@@ -0,0 +1,165 @@
+/**
+ * Test that entries logged while the disk is full are held in memory and written in order once
+ * space returns, and that shutdown makes a last attempt at writing them and reports the ones it
+ * couldn't, using fail_writes.js to make writes fail with ENOSPC while a flag file exists
+ */
+
+import { Logger, DURABILITY } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+import { pathToFileURL } from "url";
+
+const basePath = "./disk_full_test";
+
+/**
+ * Waits the given number of milliseconds
+ */
+function sleep(ms) {
+  return new Promise((resolve) => setTimeout(resolve, ms));
+}
+
+if (process.argv[2] === "child" && process.argv[4]) {
+  const [flag, mode] = process.argv.slice(3);
+  const logger = new Logger({
+    saveToLogFiles: true,
+    basePath,
+  });
+
+  logger.info("before full");
+  await logger.flush(DURABILITY.FSYNC);
+
+  await fs.writeFile(flag, "");
+  logger.info("while full 0");
+  logger.info("while full 1");
+  await sleep(500);
+
+  // well before the next retry, so only shutdown writes them
+  if (mode === "freed") await fs.rm(flag);
+  await logger.shutdown();
+} else if (process.argv[2] === "child") {
+  const flag = process.argv[3];
+  const logger = new Logger({
+    saveToLogFiles: true,
+    basePath,
+  });
+
+  // fsynced so the entry was written before writes start failing
+  logger.info("before full");
+  await logger.flush(DURABILITY.FSYNC);
+
+  await fs.writeFile(flag, "");
+  for (let i = 0; i < 3; i++) {
+    logger.info(`while full ${i}`);
+  }
+  await sleep(500);
+  await fs.rm(flag);
+
+  // the worker checks for space again every 5 seconds
+  await sleep(5500);
+  logger.info("after full");
+  await logger.flush();
+  await logger.shutdown();
+} else {
+  main();
+}
+
+/**
+ * Runs the child with writes failing while the flag exists and returns its stderr
+ * @param {string[]} args The arguments after the flag, selecting the child's mode
+ */
+function runChild(flag, ...args) {
+  const failWrites = pathToFileURL(path.resolve("tests/fail_writes.js"));
+  failWrites.search = new URLSearchParams({ code: "ENOSPC", flag }).toString();
+
+  const child = spawnSync(
+    process.execPath,
+    ["--import", failWrites.href, process.argv[1], "child", flag, ...args],
+    { encoding: "utf-8", env: { ...process.env, NO_COLOR: "1" }, timeout: 20000 },
+  );
+  if (child.status !== 0) {
+    throw new Error(`Child exited with ${child.status}: ${child.stderr}`);
+  }
+
+  return child.stderr;
+}
+
+/**
+ * Reads the messages of the entries in today's log file
+ */
+async function readEntries() {
+  const today = new Date().toISOString().split("T")[0];
+  const content = await fs.readFile(
+    path.join(basePath, `${today}.log`),
+    "utf-8",
+  );
+
+  return content
+    .trim()
+    .split("\n")
+    .map((line) => line.split("[INFO]: ")[1]);
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+    await fs.mkdir(basePath, { recursive: true });
+
+    const flag = path.resolve(basePath, "full.flag");
+    let stderr = runChild(flag);
+
+    if (!stderr.includes("Disk is full, holding up to")) {
+      throw new Error(`Disk full not reported: ${stderr}`);
+    }
+    if (!stderr.includes("Disk has space again, wrote 3 held lines")) {
+      throw new Error(`Recovery not reported: ${stderr}`);
+    }
+    console.log("✓ Disk full and recovery reported on stderr");
+
+    let entries = await readEntries();
+    const expected = [
+      "before full",
+      "while full 0",
+      "while full 1",
+      "while full 2",
+      "after full",
+    ];
+    if (entries.join() !== expected.join()) {
+      throw new Error(`Expected ${expected.join(", ")}, got ${entries.join(", ")}`);
+    }
+    console.log("✓ Held entries written once, in order, when space returned");
+
+    await fs.rm(basePath, { recursive: true, force: true });
+    await fs.mkdir(basePath, { recursive: true });
+    stderr = runChild(flag, "freed");
+
+    entries = await readEntries();
+    if (entries.join() !== "before full,while full 0,while full 1") {
+      throw new Error(`Expected the held entries after shutdown, got ${entries.join(", ")}`);
+    }
+    if (!stderr.includes("Disk has space again, wrote 2 held lines")) {
+      throw new Error(`Write at shutdown not reported: ${stderr}`);
+    }
+    console.log("✓ Shutdown wrote the held entries once space returned");
+
+    await fs.rm(basePath, { recursive: true, force: true });
+    await fs.mkdir(basePath, { recursive: true });
+    stderr = runChild(flag, "full");
+
+    entries = await readEntries();
+    if (entries.join() !== "before full") {
+      throw new Error(`Expected only the entry before full, got ${entries.join(", ")}`);
+    }
+    if (!stderr.includes("Disk is still full, dropped 2 held lines")) {
+      throw new Error(`Held entries lost at shutdown not reported: ${stderr}`);
+    }
+    console.log("✓ Shutdown reported the held entries it couldn't write");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
@@ -0,0 +1,106 @@
+/**
+ * Test that entries dropped by the rate limit and by collapsing repeats are summarised by the
+ * periodic report when no later entry arrives, and that repeats after the report stay collapsed
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./drop_reports_test";
+
+/**
+ * Waits the given number of milliseconds
+ */
+function sleep(ms) {
+  return new Promise((resolve) => setTimeout(resolve, ms));
+}
+
+if (process.argv[2] === "child") {
+  // the reports run every minute, much sooner here
+  const realSetInterval = setInterval;
+  globalThis.setInterval = (callback, ms, ...args) =>
+    realSetInterval(callback, ms === 60000 ? 200 : ms, ...args);
+
+  const logger = new Logger({
+    saveToLogFiles: true,
+    basePath,
+    maxLinesPerSecond: 5,
+    collapseRepeats: { threshold: 1 },
+  });
+
+  for (let i = 0; i < 8; i++) {
+    logger.info(`burst ${i}`);
+  }
+  await sleep(1100);
+
+  for (let i = 0; i < 4; i++) {
+    logger.warn("connection refused");
+  }
+  await sleep(500);
+
+  for (let i = 0; i < 2; i++) {
+    logger.warn("connection refused");
+  }
+  await sleep(500);
+
+  await logger.flush();
+  await logger.shutdown();
+} else {
+  main();
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const child = spawnSync(process.execPath, [process.argv[1], "child"], {
+      encoding: "utf-8",
+      env: { ...process.env, NO_COLOR: "1" },
+    });
+    if (child.status !== 0) {
+      throw new Error(`Child exited with ${child.status}: ${child.stderr}`);
+    }
+
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+
+    const expected = [
+      "burst 0",
+      "burst 1",
+      "burst 2",
+      "burst 3",
+      "burst 4",
+      "Suppressed 3 lines over the limit of 5 per second",
+      "connection refused",
+      "Last message repeated 3 times",
+      "Last message repeated 2 times",
+    ];
+
+    for (const [name, output] of [
+      ["Console", child.stdout],
+      ["Log file", content],
+    ]) {
+      const entries = output
+        .trim()
+        .split("\n")
+        .map((line) => line.split(/\[(?:INFO|WARN)\]: /)[1]);
+
+      if (entries.join() !== expected.join()) {
+        throw new Error(`${name} has ${entries.join(", ")}`);
+      }
+      console.log(`✓ ${name}: suppressed and collapsed entries reported without a later entry`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
@@ -0,0 +1,85 @@
+/**
+ * Test that entries dropped by minLevel are summarised in the log on shutdown and when the
+ * process exits without shutting down
+ */
+
+import { Logger, LOG_LEVEL } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./dropped_report_test";
+
+/**
+ * Logs one kept entry and three dropped by minLevel
+ */
+function logEntries() {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    minLevel: LOG_LEVEL.WARN,
+  });
+
+  logger.warn("kept");
+  for (let i = 0; i < 3; i++) {
+    logger.debug(`dropped ${i}`);
+  }
+
+  return logger;
+}
+
+if (process.argv[2] === "child") {
+  logEntries();
+  setTimeout(() => process.exit(0), 50);
+} else {
+  main();
+}
+
+/**
+ * Reads today's log file
+ */
+async function readLog() {
+  const today = new Date().toISOString().split("T")[0];
+  return fs.readFile(path.join(basePath, `${today}.log`), "utf-8");
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const logger = logEntries();
+    await logger.shutdown();
+
+    let content = await readLog();
+    if (!content.includes("Dropped entries since the last report: minLevel=3")) {
+      throw new Error(`Missing dropped entries report: ${content}`);
+    }
+    if (content.includes("dropped 0")) {
+      throw new Error("Entry below minLevel was written");
+    }
+    console.log("✓ minLevel drops reported on shutdown");
+
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const child = spawnSync(process.execPath, [process.argv[1], "child"], {
+      stdio: "inherit",
+    });
+    if (child.status !== 0) {
+      throw new Error(`Child exited with ${child.status}`);
+    }
+
+    content = await readLog();
+    if (!content.includes("Dropped entries since the last report: minLevel=3")) {
+      throw new Error(`Missing dropped entries report after exit: ${content}`);
+    }
+    console.log("✓ minLevel drops reported on exit");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
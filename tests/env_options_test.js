@@ -0,0 +1,67 @@
+/**
+ * Test that invalid NODE_LOGGER_* formats are only rejected when the constructor doesn't set
+ * the option they would fill in
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+
+if (process.argv[2] === "child") {
+  const options = JSON.parse(process.argv[3]);
+
+  try {
+    new Logger({ saveToLogFiles: false, ...options });
+    console.log("created");
+  } catch (error) {
+    console.log(error.name);
+  }
+} else {
+  main();
+}
+
+/**
+ * Creates a logger in a child process with bad format variables
+ * @param {object} options Options passed to the constructor
+ * @returns {string} "created" or the name of the error thrown
+ */
+function createWithBadEnv(options) {
+  const child = spawnSync(
+    process.execPath,
+    [process.argv[1], "child", JSON.stringify(options)],
+    {
+      encoding: "utf-8",
+      env: {
+        ...process.env,
+        NODE_LOGGER_FORMAT: "xml",
+        NODE_LOGGER_CONSOLE_FORMAT: "yaml",
+      },
+    },
+  );
+  return child.stdout.trim();
+}
+
+function main() {
+  try {
+    const cases = [
+      [{}, "LoggerInitializationError"],
+      [{ fileFormat: "text" }, "LoggerInitializationError"],
+      [{ consoleFormat: "text" }, "LoggerInitializationError"],
+      [{ fileFormat: "json", consoleFormat: "text" }, "created"],
+    ];
+
+    for (const [options, expected] of cases) {
+      const result = createWithBadEnv(options);
+      if (result !== expected) {
+        throw new Error(
+          `Expected ${expected} for ${JSON.stringify(options)}, got ${result}`,
+        );
+      }
+      console.log(`✓ ${JSON.stringify(options)}: ${expected}`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  }
+}
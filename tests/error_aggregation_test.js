@@ -0,0 +1,112 @@
+/**
+ * Test that repeated worker errors are summarised on the console while every occurrence is
+ * kept in the diagnostic file, including summaries still pending when the process exits
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./error_aggregation_test";
+
+/**
+ * Sends requests the worker rejects, each one is reported as an error
+ * @param {Logger} logger The logger whose worker gets the requests
+ * @param {number} count How many to send
+ */
+function sendRejected(logger, count) {
+  for (let i = 0; i < count; i++) {
+    logger._worker.postMessage([{ method: 0x01, level: 0x01, payload: i }]);
+  }
+}
+
+if (process.argv[2] === "child") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    onError: () => {},
+  });
+
+  logger.info("before exit");
+  await logger.flush();
+  sendRejected(logger, 3);
+  await logger.status();
+  process.exit(0);
+} else {
+  main();
+}
+
+/**
+ * Reads today's diagnostic file
+ */
+async function readDiagnostics() {
+  const today = new Date().toISOString().split("T")[0];
+  return fs.readFile(
+    path.join(basePath, `diagnostics-${today}.log`),
+    "utf-8",
+  );
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const errors = [];
+    const logger = new Logger({
+      saveToLogFiles: true,
+      outputToConsole: false,
+      basePath,
+      onError: (error) => errors.push(error.message),
+    });
+
+    logger.info("first");
+    await logger.flush();
+    sendRejected(logger, 5);
+    await logger.status();
+    await logger.shutdown();
+
+    const rejections = errors.filter((message) =>
+      message.includes("Rejected request"),
+    );
+    if (
+      rejections.length !== 2 ||
+      !rejections[1].includes("Rejected request repeated 4 times")
+    ) {
+      throw new Error(`Expected one error and a summary: ${rejections}`);
+    }
+    console.log("✓ Console got the first error and a summary at shutdown");
+
+    let lines = (await readDiagnostics()).trim().split("\n");
+    if (lines.length !== 5) {
+      throw new Error(`Expected 5 recorded occurrences, got ${lines.length}`);
+    }
+    console.log("✓ Every occurrence recorded in the diagnostic file");
+
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const child = spawnSync(process.execPath, [process.argv[1], "child"], {
+      stdio: "inherit",
+    });
+    if (child.status !== 0) {
+      throw new Error(`Child exited with ${child.status}`);
+    }
+
+    lines = (await readDiagnostics()).trim().split("\n");
+    if (
+      lines.length !== 4 ||
+      !lines[3].includes("Rejected request repeated 2 times")
+    ) {
+      throw new Error(`Summary pending at exit was lost: ${lines.join("\n")}`);
+    }
+    console.log("✓ Summary pending at exit recorded in the diagnostic file");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
@@ -0,0 +1,83 @@
+/**
+ * Test that errorContextSize writes the latest entries below minLevel to the errors only log file
+ * ahead of the next error, while the combined file still leaves them out
+ */
+
+import { Logger, LOG_LEVEL } from "../dist/index.js";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./error_context_test";
+
+/**
+ * Reads the messages of the entries in a log file
+ * @param {string} fileName Name of the log file
+ */
+async function readEntries(fileName) {
+  const content = await fs.readFile(path.join(basePath, fileName), "utf-8");
+
+  return content
+    .trim()
+    .split("\n")
+    .map((line) => line.split(/\[[A-Z]+\]: /)[1]);
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const logger = new Logger({
+      saveToLogFiles: true,
+      outputToConsole: false,
+      basePath,
+      minLevel: LOG_LEVEL.INFO,
+      splitErrorLogFile: true,
+      errorContextSize: 2,
+    });
+
+    logger.debug("debug 1");
+    logger.debug("debug 2");
+    logger.debug("debug 3");
+    logger.info("info 1");
+    logger.error("error 1");
+    logger.debug("debug 4");
+    logger.error("error 2");
+    await logger.flush();
+    await logger.shutdown();
+
+    const today = new Date().toISOString().split("T")[0];
+
+    // still counted as dropped by minLevel
+    let entries = await readEntries(`${today}.log`);
+    if (
+      entries.join() !==
+      "info 1,error 1,error 2,Dropped entries since the last report: minLevel=4"
+    ) {
+      throw new Error(`Log file has ${entries.join(", ")}`);
+    }
+    console.log("✓ Log file leaves out the entries below minLevel");
+
+    entries = await readEntries(`${today}.error.log`);
+    if (entries.join() !== "debug 2,debug 3,error 1,debug 4,error 2") {
+      throw new Error(`Error log file has ${entries.join(", ")}`);
+    }
+    console.log("✓ Error log file has the latest entries before each error");
+
+    try {
+      new Logger({ saveToLogFiles: false, errorContextSize: 2 });
+      throw new Error("errorContextSize without splitErrorLogFile was accepted");
+    } catch (error) {
+      if (!error.message.includes("needs splitErrorLogFile")) throw error;
+    }
+    console.log("✓ errorContextSize without splitErrorLogFile throws");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
@@ -0,0 +1,65 @@
+/**
+ * Test that entries are written when the process exits without shutting the logger down
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./exit_drain_test";
+
+if (process.argv[2] === "child") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+  });
+
+  for (let i = 0; i < 10; i++) {
+    logger.info(`entry ${i}`);
+  }
+
+  // once straight away and once after the batch reached the worker
+  setTimeout(() => process.exit(0), Number(process.argv[3]));
+} else {
+  main();
+}
+
+async function main() {
+  try {
+    for (const delay of [0, 150]) {
+      await fs.rm(basePath, { recursive: true, force: true });
+
+      const child = spawnSync(
+        process.execPath,
+        [process.argv[1], "child", String(delay)],
+        { stdio: "inherit" },
+      );
+      if (child.status !== 0) {
+        throw new Error(`Child exited with ${child.status}`);
+      }
+
+      const today = new Date().toISOString().split("T")[0];
+      const content = await fs.readFile(
+        path.join(basePath, `${today}.log`),
+        "utf-8",
+      );
+      const lines = content.trim().split("\n");
+
+      if (lines.length !== 10 || !content.includes("[INFO]: entry 9")) {
+        throw new Error(
+          `Expected 10 lines after exiting at ${delay}ms, got ${lines.length}`,
+        );
+      }
+      console.log(`✓ All 10 lines written after exiting at ${delay}ms`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
@@ -0,0 +1,42 @@
+/**
+ * Preloaded with --import to make the worker's file writes fail, used by the disk full and write
+ * retry tests. Workers don't inherit the environment so it is configured through the query
+ * string: `code` is the error code, then either `flag` is a file that makes writes fail while it
+ * exists or `count` is how many of the first writes fail
+ */
+
+import fs from "fs";
+import { isMainThread } from "worker_threads";
+
+const params = new URL(import.meta.url).searchParams;
+const code = params.get("code") ?? "EIO";
+const flag = params.get("flag");
+let failures = Number(params.get("count") ?? 0);
+
+/**
+ * Checks if the next write should fail
+ */
+function shouldFail() {
+  if (flag) return fs.existsSync(flag);
+  if (failures === 0) return false;
+
+  failures--;
+  return true;
+}
+
+if (!isMainThread) {
+  for (const method of ["write", "writev"]) {
+    const original = fs[method];
+
+    fs[method] = (fd, ...args) => {
+      if (!shouldFail()) return original(fd, ...args);
+
+      const callback = args[args.length - 1];
+      const error = Object.assign(new Error(`${code}: simulated failure`), {
+        code,
+        syscall: method,
+      });
+      process.nextTick(callback, error);
+    };
+  }
+}
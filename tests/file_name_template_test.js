@@ -0,0 +1,84 @@
+/**
+ * Test that every token in the file name template is substituted, including templates
+ * where a token comes after the last dot
+ */
+
+import { Logger } from "../dist/index.js";
+import fs from "fs/promises";
+import os from "os";
+
+const basePath = "./file_name_template_test";
+
+async function main() {
+  const now = new Date();
+  const today = now.toISOString().split("T")[0];
+  const hour = String(now.getUTCHours()).padStart(2, "0");
+  const host = os.hostname();
+
+  const cases = [
+    {
+      template: "app.{date}",
+      expected: [
+        `app.${today}.1`,
+        `app.${today}.error`,
+        `app.${today}.gz`,
+      ],
+    },
+    {
+      template: "{date}.{hour}",
+      expected: [
+        `${today}.${hour}.1`,
+        `${today}.${hour}.error`,
+        `${today}.${hour}.gz`,
+      ],
+    },
+    {
+      template: "app-{date}-{host}.log",
+      expected: [
+        `app-${today}-${host}.1.log`,
+        `app-${today}-${host}.error.log`,
+        `app-${today}-${host}.log.gz`,
+      ],
+    },
+  ];
+
+  try {
+    for (const { template, expected } of cases) {
+      await fs.rm(basePath, { recursive: true, force: true });
+
+      const logger = new Logger({
+        saveToLogFiles: true,
+        outputToConsole: false,
+        basePath,
+        timeZone: "UTC",
+        fileNameTemplate: template,
+        compressOldLogFiles: true,
+        splitErrorLogFile: true,
+      });
+
+      logger.error("before rotate");
+      await logger.flush();
+      await logger.rotate();
+      logger.info("after rotate");
+      await logger.flush();
+      await logger.shutdown();
+
+      const fileNames = (await fs.readdir(basePath)).sort();
+      if (JSON.stringify(fileNames) !== JSON.stringify(expected.sort())) {
+        throw new Error(
+          `${template} produced ${fileNames.join(", ")}, expected ${expected.join(", ")}`,
+        );
+      }
+      console.log(`✓ ${template}: ${fileNames.join(", ")}`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
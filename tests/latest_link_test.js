@@ -0,0 +1,113 @@
+/**
+ * Test that latest.log always shows the current log file, after writes and after a rotation,
+ * with a symlink and with the hard link used on Windows
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./latest_link_test";
+
+// workers inherit execArgv, so this makes only the worker believe it runs on Windows
+const windowsWorker =
+  "data:text/javascript,import{isMainThread}from'worker_threads';" +
+  "if(!isMainThread)Object.defineProperty(process,'platform',{value:'win32'})";
+
+/**
+ * The link is replaced in the background once a file is opened, give it a moment
+ */
+function settle() {
+  return new Promise((resolve) => setTimeout(resolve, 100));
+}
+
+if (process.argv[2] === "child") {
+  const latestPath = path.join(basePath, "latest.log");
+  const errors = [];
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    linkLatestLogFile: true,
+    basePath,
+    onError: (error) => errors.push(error.message),
+  });
+
+  try {
+    logger.info("first entry");
+    await logger.flush();
+    logger.info("second entry");
+    await logger.flush();
+    await settle();
+
+    let content = await fs.readFile(latestPath, "utf-8");
+    if (!content.includes("first entry") || !content.includes("second entry")) {
+      throw new Error(`latest.log is behind the log file: ${content}`);
+    }
+
+    await logger.rotate();
+    logger.info("after rotate");
+    await logger.flush();
+    await settle();
+
+    content = await fs.readFile(latestPath, "utf-8");
+    if (content.includes("first entry") || !content.includes("after rotate")) {
+      throw new Error(`latest.log does not show the rotated file: ${content}`);
+    }
+
+    // back to back rotations replace the link while the previous update may still be running
+    await logger.rotate();
+    await logger.rotate();
+    await logger.rotate();
+    await settle();
+
+    const { filePath } = await logger.status();
+    const [latest, current] = await Promise.all([
+      fs.stat(latestPath),
+      fs.stat(filePath),
+    ]);
+    if (latest.ino !== current.ino) {
+      throw new Error(`latest.log is not ${filePath} after rotating quickly`);
+    }
+    if (errors.length > 0) {
+      throw new Error(`Updating the link failed: ${errors.join(", ")}`);
+    }
+
+    const fileNames = await fs.readdir(basePath);
+    if (fileNames.some((fileName) => fileName.endsWith(".tmp"))) {
+      throw new Error(`Temporary link left behind: ${fileNames.join(", ")}`);
+    }
+  } finally {
+    await logger.shutdown();
+  }
+} else {
+  main();
+}
+
+async function main() {
+  try {
+    for (const [name, execArgv] of [
+      ["symlink", []],
+      ["Windows hard link", ["--import", windowsWorker]],
+    ]) {
+      await fs.rm(basePath, { recursive: true, force: true });
+
+      const child = spawnSync(
+        process.execPath,
+        [...execArgv, process.argv[1], "child"],
+        { stdio: "inherit" },
+      );
+      if (child.status !== 0) {
+        throw new Error(`${name} child exited with ${child.status}`);
+      }
+      console.log(`✓ ${name}: latest.log follows writes and quick rotations`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
@@ -0,0 +1,167 @@
+/**
+ * Test that maxTotalSize deletes the oldest log files first and leaves the current file and
+ * files the logger doesn't manage alone, that files of earlier processes and the logger's
+ * side files count towards it, and that status reports what the next check will delete
+ */
+
+import { Logger } from "../dist/index.js";
+import fs from "fs/promises";
+import os from "os";
+import path from "path";
+
+const basePath = "./max_total_size_test";
+
+/**
+ * Writes 1000 byte files modified a day apart, oldest first
+ * @param {string[]} oldFiles Names of the files
+ */
+async function writeOldFiles(oldFiles) {
+  await fs.rm(basePath, { recursive: true, force: true });
+  await fs.mkdir(basePath, { recursive: true });
+
+  for (const [index, fileName] of oldFiles.entries()) {
+    const filePath = path.join(basePath, fileName);
+    await fs.writeFile(filePath, "x".repeat(1000));
+
+    const modified = new Date(Date.UTC(2020, 0, index + 1));
+    await fs.utimes(filePath, modified, modified);
+  }
+}
+
+/**
+ * Logs an entry with the limit set and returns the files left in the base path
+ * @param {object} options Options added to the logger's
+ */
+async function logWithLimit(options = {}) {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    maxTotalSize: 2500,
+    ...options,
+  });
+
+  logger.info("current entry");
+  await logger.flush();
+  // the limit is enforced in the background once the file is opened
+  await new Promise((resolve) => setTimeout(resolve, 200));
+  await logger.shutdown();
+
+  return fs.readdir(basePath);
+}
+
+async function main() {
+  try {
+    const today = new Date().toISOString().split("T")[0];
+
+    await writeOldFiles([
+      "2020-01-01.log",
+      "2020-01-02.1.log",
+      "2020-01-03.log.gz",
+    ]);
+    await fs.writeFile(path.join(basePath, "notes.txt"), "x".repeat(5000));
+    let fileNames = await logWithLimit();
+
+    if (fileNames.includes("2020-01-01.log")) {
+      throw new Error("Oldest file was not deleted");
+    }
+    console.log("✓ Oldest file deleted");
+
+    for (const fileName of [
+      "2020-01-02.1.log",
+      "2020-01-03.log.gz",
+      "notes.txt",
+      `${today}.log`,
+    ]) {
+      if (!fileNames.includes(fileName)) {
+        throw new Error(`${fileName} was deleted`);
+      }
+      console.log(`✓ Kept ${fileName}`);
+    }
+
+    // no pid is this high, while the parent is running
+    const deadPid = 99999999;
+    const livePid = process.ppid;
+    await writeOldFiles([
+      `.node-logy.${os.hostname()}.${livePid}.1.wal`,
+      `app-2020-01-01-${deadPid}.log`,
+      "diagnostics-2020-01-02.log",
+      `app-2020-01-03-${livePid}.log`,
+      "rejected-2020-01-04.log",
+    ]);
+    fileNames = await logWithLimit({ fileNameTemplate: "app-{date}-{pid}.log" });
+
+    for (const fileName of [
+      `app-2020-01-01-${deadPid}.log`,
+      "diagnostics-2020-01-02.log",
+      "rejected-2020-01-04.log",
+    ]) {
+      if (fileNames.includes(fileName)) {
+        throw new Error(`${fileName} was not deleted`);
+      }
+      console.log(`✓ Deleted ${fileName}`);
+    }
+
+    for (const fileName of [
+      `.node-logy.${os.hostname()}.${livePid}.1.wal`,
+      `app-2020-01-03-${livePid}.log`,
+      `app-${today}-${process.pid}.log`,
+    ]) {
+      if (!fileNames.includes(fileName)) {
+        throw new Error(`${fileName} was deleted`);
+      }
+      console.log(`✓ Kept ${fileName}`);
+    }
+
+    await fs.rm(basePath, { recursive: true, force: true });
+    const logger = new Logger({
+      saveToLogFiles: true,
+      outputToConsole: false,
+      basePath,
+      maxTotalSize: 2500,
+    });
+    logger.info("current entry");
+    await logger.flush();
+    await new Promise((resolve) => setTimeout(resolve, 200));
+
+    // added after the check at startup so they wait for the next one
+    const oldFiles = ["2020-01-01.log", "2020-01-02.log", "2020-01-03.log"];
+    for (const [index, fileName] of oldFiles.entries()) {
+      const filePath = path.join(basePath, fileName);
+      await fs.writeFile(filePath, "x".repeat(1000));
+
+      const modified = new Date(Date.UTC(2020, 0, index + 1));
+      await fs.utimes(filePath, modified, modified);
+    }
+
+    const { retention } = await logger.status();
+    fileNames = await fs.readdir(basePath);
+    await logger.shutdown();
+
+    if (
+      retention?.candidates.join() !== "2020-01-01.log" ||
+      retention.candidateBytes !== 1000
+    ) {
+      throw new Error(`status reported ${JSON.stringify(retention)}`);
+    }
+    if (!fileNames.includes("2020-01-01.log")) {
+      throw new Error("Reporting the next check deleted a file");
+    }
+    console.log("✓ status reports the next deletion without deleting it");
+
+    const untilSweep = retention.nextSweepAt - Date.now();
+    if (untilSweep <= 0 || untilSweep > 60000) {
+      throw new Error(`Next check reported ${untilSweep}ms away`);
+    }
+    console.log("✓ status reports when the next check runs");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
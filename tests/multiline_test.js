@@ -0,0 +1,63 @@
+/**
+ * Test that splitMultilineMessages logs each line as its own entry and never drops a call
+ */
+
+import { Logger } from "../dist/index.js";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./multiline_test";
+
+async function main() {
+  await fs.rm(basePath, { recursive: true, force: true });
+
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    splitMultilineMessages: true,
+  });
+
+  logger.info("first\nsecond\r\n\n  \nthird");
+  logger.info("");
+  logger.warn("\n \t\n");
+
+  await logger.flush();
+  await logger.shutdown();
+
+  try {
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+    const lines = content.split("\n").slice(0, -1);
+
+    const expected = [
+      "[INFO]: first",
+      "[INFO]: second",
+      "[INFO]: third",
+      "[INFO]: ",
+      "[WARN]: ",
+    ];
+    if (lines.length !== expected.length) {
+      throw new Error(`Expected ${expected.length} lines, got ${lines.length}`);
+    }
+
+    for (let i = 0; i < expected.length; i++) {
+      if (!lines[i].endsWith(expected[i])) {
+        throw new Error(`Line ${i} is "${lines[i]}", expected "${expected[i]}"`);
+      }
+      console.log(`✓ Line ${i}: ${expected[i]}`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
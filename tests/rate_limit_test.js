@@ -0,0 +1,82 @@
+/**
+ * Test that maxLinesPerSecond drops entries over the limit and summarises them once the rate
+ * allows again, on the console and in the log file
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./rate_limit_test";
+
+if (process.argv[2] === "child") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    basePath,
+    maxLinesPerSecond: 5,
+  });
+
+  for (let i = 0; i < 20; i++) {
+    logger.info(`burst ${i}`);
+  }
+
+  await new Promise((resolve) => setTimeout(resolve, 1100));
+  logger.info("after burst");
+  await logger.flush();
+  await logger.shutdown();
+} else {
+  main();
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const child = spawnSync(process.execPath, [process.argv[1], "child"], {
+      encoding: "utf-8",
+      env: { ...process.env, NO_COLOR: "1" },
+    });
+    if (child.status !== 0) {
+      throw new Error(`Child exited with ${child.status}: ${child.stderr}`);
+    }
+
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+
+    const expected = [
+      "burst 0",
+      "burst 1",
+      "burst 2",
+      "burst 3",
+      "burst 4",
+      "Suppressed 15 lines over the limit of 5 per second",
+      "after burst",
+    ];
+
+    for (const [name, output] of [
+      ["Console", child.stdout],
+      ["Log file", content],
+    ]) {
+      const entries = output
+        .trim()
+        .split("\n")
+        .map((line) => line.split("[INFO]: ")[1]);
+
+      if (entries.join() !== expected.join()) {
+        throw new Error(`${name} has ${entries.join(", ")}`);
+      }
+      console.log(`✓ ${name}: 5 entries kept and 15 summarised`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
@@ -0,0 +1,76 @@
+/**
+ * Test that readLast returns the most recent entries oldest first, before and after the ring
+ * buffer wraps around, limited by count, and nothing when recentEntriesSize is 0
+ */
+
+import { Logger } from "../dist/index.js";
+
+/**
+ * Gets the messages of formatted entries
+ * @param {string[]} entries The entries returned by readLast
+ */
+function getMessages(entries) {
+  return entries.map((entry) => entry.split("[INFO]: ")[1]).join();
+}
+
+/**
+ * Checks the messages readLast returned
+ * @param {string[]} entries The entries returned by readLast
+ * @param {string} expected The messages joined with commas
+ * @param {string} description What was read
+ */
+function expectMessages(entries, expected, description) {
+  const found = getMessages(entries);
+  if (found !== expected) {
+    throw new Error(`${description} returned ${found}, expected ${expected}`);
+  }
+  console.log(`✓ ${description}: ${expected || "nothing"}`);
+}
+
+async function main() {
+  try {
+    const logger = new Logger({
+      saveToLogFiles: false,
+      outputToConsole: false,
+      recentEntriesSize: 3,
+    });
+
+    logger.info("entry 1");
+    logger.info("entry 2");
+    expectMessages(logger.readLast(), "entry 1,entry 2", "Before wrapping around");
+
+    for (let i = 3; i <= 7; i++) {
+      logger.info(`entry ${i}`);
+    }
+    expectMessages(
+      logger.readLast(),
+      "entry 5,entry 6,entry 7",
+      "After wrapping around",
+    );
+
+    expectMessages(logger.readLast(2), "entry 6,entry 7", "readLast(2)");
+    expectMessages(logger.readLast(0), "", "readLast(0)");
+    expectMessages(
+      logger.readLast(10),
+      "entry 5,entry 6,entry 7",
+      "readLast(10)",
+    );
+    await logger.shutdown();
+
+    const disabled = new Logger({
+      saveToLogFiles: false,
+      outputToConsole: false,
+      recentEntriesSize: 0,
+    });
+    disabled.info("not kept");
+    expectMessages(disabled.readLast(), "", "recentEntriesSize 0");
+    await disabled.shutdown();
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  }
+}
+
+main();
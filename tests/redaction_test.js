@@ -0,0 +1,70 @@
+/**
+ * Test that redactKeys masks sensitive values and leaves everything else untouched
+ */
+
+import { Logger } from "../dist/index.js";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./redaction_test";
+
+async function main() {
+  await fs.rm(basePath, { recursive: true, force: true });
+
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    redactKeys: ["password", "Token"],
+  });
+
+  const error = new Error("login failed");
+  error.password = "hunter2";
+
+  logger.info({ user: "alice", password: "hunter2" });
+  logger.info('{"user": {"name": "bob", "token": "abc123"}}');
+  logger.info('{"id": 12345678901234567890}');
+  logger.info("[1,  2, 3]");
+  logger.error(error);
+
+  await logger.flush();
+  await logger.shutdown();
+
+  try {
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+
+    const expected = [
+      "user: alice, password: ***",
+      '{"user":{"name":"bob","token":"***"}}',
+      '{"id": 12345678901234567890}',
+      "[1,  2, 3]",
+      "password: ***",
+    ];
+    for (const text of expected) {
+      if (!content.includes(text)) {
+        throw new Error(`Missing "${text}"`);
+      }
+      console.log(`✓ Contains ${text}`);
+    }
+
+    for (const secret of ["hunter2", "abc123"]) {
+      if (content.includes(secret)) {
+        throw new Error(`Secret "${secret}" was written to the log file`);
+      }
+    }
+    console.log("✓ No secrets written");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
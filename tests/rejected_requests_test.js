@@ -0,0 +1,84 @@
+/**
+ * Test that malformed requests reaching the worker are recorded in the rejection log
+ * instead of crashing it
+ */
+
+import { Logger } from "../dist/index.js";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./rejected_requests_test";
+
+async function main() {
+  await fs.rm(basePath, { recursive: true, force: true });
+
+  const errors = [];
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    onError: (error) => errors.push(error.message),
+  });
+
+  logger.info("before");
+  await logger.flush();
+
+  // bypass the logger so the worker sees requests it would never build itself
+  logger._worker.postMessage([
+    { method: 0x01, level: 0x01, payload: 42 },
+    { method: 0x01, level: 0x99, payload: "bad level" },
+    { method: 0x63, payload: "" },
+    null,
+  ]);
+  logger._worker.postMessage("not a batch");
+
+  logger.info("after");
+  await logger.flush();
+  await logger.shutdown();
+
+  try {
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+    if (!content.includes("before") || !content.includes("after")) {
+      throw new Error(`Worker stopped writing entries: ${content}`);
+    }
+    if (content.includes("bad level")) {
+      throw new Error("Request with an unknown level was written");
+    }
+    console.log("✓ Worker kept writing after the bad requests");
+
+    const rejected = await fs.readFile(
+      path.join(basePath, `rejected-${today}.log`),
+      "utf-8",
+    );
+    for (const reason of [
+      "payload must be a string, got number: ",
+      "unknown level 153: ",
+      "unknown method 99: ",
+      "request is not an object: null",
+      'batch is not an array: "not a batch"',
+    ]) {
+      if (!rejected.includes(reason)) {
+        throw new Error(`Rejection log is missing "${reason}": ${rejected}`);
+      }
+      console.log(`✓ Recorded ${reason.split(":")[0]}`);
+    }
+
+    if (!errors.some((message) => message.includes("Rejected request"))) {
+      throw new Error(`Rejections were not reported: ${errors.join(", ")}`);
+    }
+    console.log("✓ Rejections reported through onError");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
@@ -0,0 +1,137 @@
+/**
+ * Test that the first write after midnight rolls over to a new day's file, compressing the old
+ * file and its errors only file and moving latest.log, using shift_date.js to move the worker's
+ * clock a day ahead while a flag file exists
+ */
+
+import { Logger, DURABILITY } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+import { pathToFileURL } from "url";
+import zlib from "zlib";
+
+const basePath = "./rollover_test";
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+if (process.argv[2] === "child") {
+  const flag = process.argv[3];
+  const logger = new Logger({
+    saveToLogFiles: true,
+    basePath,
+    timeZone: "UTC",
+    compressOldLogFiles: true,
+    linkLatestLogFile: true,
+    splitErrorLogFile: true,
+  });
+
+  logger.info("day 1");
+  logger.error("day 1 error");
+  await logger.flush(DURABILITY.FSYNC);
+
+  await fs.writeFile(flag, "");
+  logger.info("day 2");
+  logger.error("day 2 error");
+  await logger.flush(DURABILITY.FSYNC);
+
+  // the old files are compressed once the closed stream has ended, shutdown waits for it
+  await new Promise((resolve) => setTimeout(resolve, 100));
+  await logger.shutdown();
+} else {
+  main();
+}
+
+/**
+ * Reads a log file, unpacking it when it is an archive
+ * @param {string} fileName Name of the file in the base path
+ */
+async function readLogFile(fileName) {
+  const data = await fs.readFile(path.join(basePath, fileName));
+  return fileName.endsWith(".gz")
+    ? zlib.gunzipSync(data).toString()
+    : data.toString();
+}
+
+/**
+ * Gets the messages of the entries in a log file
+ * @param {string} content Content of the log file
+ */
+function getEntries(content) {
+  return content
+    .trim()
+    .split("\n")
+    .map((line) => line.split(/\[[A-Z]+\]: /)[1]);
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+    await fs.mkdir(basePath, { recursive: true });
+
+    const flag = path.resolve(basePath, "tomorrow.flag");
+    const shiftDate = pathToFileURL(path.resolve("tests/shift_date.js"));
+    shiftDate.search = new URLSearchParams({ flag, ms: DAY_MS }).toString();
+
+    const child = spawnSync(
+      process.execPath,
+      ["--import", shiftDate.href, process.argv[1], "child", flag],
+      { encoding: "utf-8", env: { ...process.env, NO_COLOR: "1" }, timeout: 20000 },
+    );
+    if (child.status !== 0) {
+      throw new Error(`Child exited with ${child.status}: ${child.stderr}`);
+    }
+
+    const now = Date.now();
+    const today = new Date(now).toISOString().split("T")[0];
+    const tomorrow = new Date(now + DAY_MS).toISOString().split("T")[0];
+
+    const fileNames = (await fs.readdir(basePath)).sort();
+    const expected = [
+      `${today}.error.log.gz`,
+      `${today}.log.gz`,
+      `${tomorrow}.error.log`,
+      `${tomorrow}.log`,
+      "latest.log",
+      "tomorrow.flag",
+    ];
+    if (fileNames.join() !== expected.join()) {
+      throw new Error(`Expected ${expected.join(", ")}, got ${fileNames.join(", ")}`);
+    }
+    console.log(`✓ Files: ${fileNames.join(", ")}`);
+
+    if (
+      !child.stderr.includes("Rolled over log file") ||
+      !child.stderr.includes(`${today}.log: entries=2`)
+    ) {
+      throw new Error(`Rollover not reported: ${child.stderr}`);
+    }
+    console.log("✓ Rollover reported with the closed file's summary");
+
+    for (const [fileName, entries] of [
+      [`${today}.log.gz`, "day 1,day 1 error"],
+      [`${today}.error.log.gz`, "day 1 error"],
+      [`${tomorrow}.log`, "day 2,day 2 error"],
+      [`${tomorrow}.error.log`, "day 2 error"],
+    ]) {
+      const found = getEntries(await readLogFile(fileName)).join();
+      if (found !== entries) {
+        throw new Error(`${fileName} has ${found}, expected ${entries}`);
+      }
+      console.log(`✓ ${fileName} has ${entries}`);
+    }
+
+    const target = await fs.readlink(path.join(basePath, "latest.log"));
+    if (target !== `${tomorrow}.log`) {
+      throw new Error(`latest.log points at ${target}`);
+    }
+    console.log("✓ latest.log points at the new day's file");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
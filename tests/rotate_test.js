@@ -0,0 +1,61 @@
+/**
+ * Test that rotate closes the current file and carries on in a new one with the next suffix
+ */
+
+import { Logger } from "../dist/index.js";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./rotate_test";
+
+async function main() {
+  await fs.rm(basePath, { recursive: true, force: true });
+
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+  });
+
+  logger.info("first file");
+  await logger.rotate();
+  logger.info("second file");
+  await logger.rotate();
+  logger.info("third file");
+  await logger.flush();
+
+  const { filePath } = await logger.status();
+  await logger.shutdown();
+
+  try {
+    const today = new Date().toISOString().split("T")[0];
+
+    for (const [fileName, entry] of [
+      [`${today}.log`, "first file"],
+      [`${today}.1.log`, "second file"],
+      [`${today}.2.log`, "third file"],
+    ]) {
+      const content = await fs.readFile(path.join(basePath, fileName), "utf-8");
+      const lines = content.trim().split("\n");
+
+      if (lines.length !== 1 || !lines[0].endsWith(`[INFO]: ${entry}`)) {
+        throw new Error(`${fileName} has ${content}`);
+      }
+      console.log(`✓ ${fileName} has only "${entry}"`);
+    }
+
+    if (path.basename(filePath) !== `${today}.2.log`) {
+      throw new Error(`status reports ${filePath} as the current file`);
+    }
+    console.log("✓ status reports the newest file as current");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
+
+main();
@@ -0,0 +1,75 @@
+/**
+ * Test that sampleRates drops entries of the sampled level and reports how many were dropped,
+ * on the console and in the log file
+ */
+
+import { Logger, LOG_LEVEL } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./sampling_test";
+
+if (process.argv[2] === "child") {
+  // every roll loses so the sampled entries are always dropped
+  Math.random = () => 0.99;
+
+  const logger = new Logger({
+    saveToLogFiles: true,
+    basePath,
+    sampleRates: { [LOG_LEVEL.DEBUG]: 10 },
+  });
+
+  for (let i = 0; i < 25; i++) {
+    logger.debug(`sampled ${i}`);
+  }
+  logger.info("not sampled");
+
+  await logger.flush();
+  await logger.shutdown();
+} else {
+  main();
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    const child = spawnSync(process.execPath, [process.argv[1], "child"], {
+      encoding: "utf-8",
+      env: { ...process.env, NO_COLOR: "1" },
+    });
+    if (child.status !== 0) {
+      throw new Error(`Child exited with ${child.status}: ${child.stderr}`);
+    }
+
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+
+    for (const [name, output] of [
+      ["Console", child.stdout],
+      ["Log file", content],
+    ]) {
+      if (output.includes("sampled 0")) {
+        throw new Error(`${name} has a sampled entry: ${output}`);
+      }
+      if (!output.includes("[INFO]: not sampled")) {
+        throw new Error(`${name} is missing the entry that isn't sampled`);
+      }
+      if (!output.includes("Sampled away entries since the last report: DEBUG=25")) {
+        throw new Error(`${name} is missing the sampling report: ${output}`);
+      }
+      console.log(`✓ ${name}: sampled entries dropped and reported`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
@@ -0,0 +1,75 @@
+/**
+ * Test that setMinLevel changes which entries are logged while running, can be cleared with
+ * undefined and throws on a level that doesn't exist
+ */
+
+import { Logger, LOG_LEVEL } from "../dist/index.js";
+
+/**
+ * Logs one entry at each built in level up to ERROR and returns the levels that were kept
+ * @param {Logger} logger The logger to log with
+ * @param {string} round Added to each entry to tell it apart from earlier rounds
+ */
+function logEachLevel(logger, round) {
+  logger.debug(round);
+  logger.info(round);
+  logger.warn(round);
+  logger.error(round);
+
+  return logger
+    .readLast()
+    .filter((entry) => entry.endsWith(`: ${round}`))
+    .map((entry) => /\[([A-Z]+)\]/.exec(entry)?.[1])
+    .join();
+}
+
+async function main() {
+  try {
+    const logger = new Logger({
+      saveToLogFiles: false,
+      outputToConsole: false,
+      recentEntriesSize: 4,
+      minLevel: LOG_LEVEL.INFO,
+    });
+
+    let kept = logEachLevel(logger, "initial");
+    if (kept !== "INFO,WARN,ERROR") {
+      throw new Error(`minLevel INFO kept ${kept}`);
+    }
+    console.log("✓ minLevel INFO drops DEBUG");
+
+    logger.setMinLevel(LOG_LEVEL.WARN);
+    kept = logEachLevel(logger, "raised");
+    if (kept !== "WARN,ERROR" || logger.options.minLevel !== LOG_LEVEL.WARN) {
+      throw new Error(`Raised to WARN kept ${kept}`);
+    }
+    console.log("✓ Raised to WARN drops DEBUG and INFO");
+
+    logger.setMinLevel(undefined);
+    kept = logEachLevel(logger, "cleared");
+    if (kept !== "DEBUG,INFO,WARN,ERROR" || logger.options.minLevel !== undefined) {
+      throw new Error(`Cleared kept ${kept}`);
+    }
+    console.log("✓ Cleared with undefined logs every level");
+
+    try {
+      logger.setMinLevel("verbose");
+      throw new Error("Unknown level was accepted");
+    } catch (error) {
+      if (!error.message.includes("Unknown log level: verbose")) throw error;
+    }
+    if (logger.options.minLevel !== undefined) {
+      throw new Error("Unknown level changed minLevel");
+    }
+    console.log("✓ Unknown level throws and leaves minLevel alone");
+
+    await logger.shutdown();
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  }
+}
+
+main();
@@ -0,0 +1,37 @@
+/**
+ * Preloaded with --import to move the worker's clock forward, used by the rollover test. Workers
+ * don't inherit the environment so it is configured through the query string: while the `flag`
+ * file exists the clock is `ms` milliseconds ahead
+ */
+
+import fs from "fs";
+import { isMainThread } from "worker_threads";
+
+const params = new URL(import.meta.url).searchParams;
+const flag = params.get("flag");
+const ms = Number(params.get("ms") ?? 0);
+
+/**
+ * How far ahead the clock is right now
+ */
+function offset() {
+  return flag && fs.existsSync(flag) ? ms : 0;
+}
+
+if (!isMainThread) {
+  const RealDate = Date;
+
+  globalThis.Date = class extends RealDate {
+    constructor(...args) {
+      if (args.length === 0) {
+        super(RealDate.now() + offset());
+      } else {
+        super(...args);
+      }
+    }
+
+    static now() {
+      return RealDate.now() + offset();
+    }
+  };
+}
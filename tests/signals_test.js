@@ -0,0 +1,68 @@
+/**
+ * Test that handleSignals writes buffered entries before SIGINT and SIGTERM end the process,
+ * and that the process still ends with the signal
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+
+const basePath = "./signals_test";
+
+if (process.argv[2] === "child") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    handleSignals: true,
+  });
+
+  for (let i = 0; i < 50; i++) {
+    logger.info(`entry ${i}`);
+  }
+
+  // still batched in the logger when the signal arrives
+  process.kill(process.pid, process.argv[3]);
+  setInterval(() => {}, 1000);
+} else {
+  main();
+}
+
+async function main() {
+  try {
+    for (const signal of ["SIGTERM", "SIGINT"]) {
+      await fs.rm(basePath, { recursive: true, force: true });
+
+      const child = spawnSync(
+        process.execPath,
+        [process.argv[1], "child", signal],
+        { encoding: "utf-8", timeout: 10000 },
+      );
+      if (child.signal !== signal) {
+        throw new Error(
+          `Expected the child to end with ${signal}, got ${child.signal ?? child.status}: ${child.stderr}`,
+        );
+      }
+      console.log(`✓ ${signal}: process still ended with the signal`);
+
+      const today = new Date().toISOString().split("T")[0];
+      const content = await fs.readFile(
+        path.join(basePath, `${today}.log`),
+        "utf-8",
+      );
+      const lines = content.trim().split("\n");
+      if (lines.length !== 50 || !content.includes("[INFO]: entry 49")) {
+        throw new Error(`Expected 50 entries, got ${lines.length}`);
+      }
+      console.log(`✓ ${signal}: all 50 buffered entries written`);
+    }
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
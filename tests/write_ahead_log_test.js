@@ -0,0 +1,184 @@
+/**
+ * Test that entries left in the write ahead log by a crashed process are written once by the
+ * next loggers to start, without touching logs of processes that are still running
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawn, spawnSync } from "child_process";
+import fs from "fs/promises";
+import os from "os";
+import path from "path";
+
+const basePath = "./write_ahead_log_test";
+
+if (process.argv[2] === "crash") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    writeAheadLog: true,
+    basePath,
+  });
+
+  await logger.status();
+  logger.info(process.argv[3]);
+
+  // killed once the entry reached the write ahead log but before the worker flushed it
+  setInterval(async () => {
+    for (const fileName of await fs.readdir(basePath)) {
+      const stat = await fs.stat(path.join(basePath, fileName));
+      if (fileName.includes(`.${process.pid}.`) && stat.size > 0) {
+        process.kill(process.pid, "SIGKILL");
+      }
+    }
+  }, 5);
+} else if (process.argv[2] === "restart") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    writeAheadLog: true,
+    basePath,
+  });
+
+  logger.info("after restart");
+  await logger.flush();
+  await logger.shutdown();
+} else if (process.argv[2] === "same-pid") {
+  // as if an earlier process with this pid crashed, the first worker of a process is thread 1
+  const walPath = path.join(
+    basePath,
+    `.node-logy.${os.hostname()}.${process.pid}.1.wal`,
+  );
+  await fs.writeFile(walPath, "left by the same pid\n");
+
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    writeAheadLog: true,
+    basePath,
+  });
+
+  logger.info("after same pid restart");
+  await logger.flush();
+
+  const kept = await fs
+    .stat(walPath)
+    .then(() => true)
+    .catch(() => false);
+  await logger.shutdown();
+
+  if (!kept) {
+    console.error("The write ahead log was removed while the worker used it");
+    process.exit(1);
+  }
+} else {
+  main();
+}
+
+/**
+ * Runs a restart child without waiting for it
+ */
+function restart() {
+  return new Promise((resolve, reject) => {
+    const child = spawn(process.execPath, [process.argv[1], "restart"], {
+      stdio: "inherit",
+    });
+    child.on("error", reject);
+    child.on("close", (code) =>
+      code === 0 ? resolve() : reject(new Error(`Restart exited with ${code}`)),
+    );
+  });
+}
+
+/**
+ * Counts how often the text appears in the content
+ */
+function count(content, text) {
+  return content.split(text).length - 1;
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+    await fs.mkdir(basePath, { recursive: true });
+
+    const running = `.node-logy.${os.hostname()}.${process.pid}.1.wal`;
+    const otherHost = ".node-logy.other-host.1.1.wal";
+    await fs.writeFile(path.join(basePath, running), "owned by a running process\n");
+    await fs.writeFile(path.join(basePath, otherHost), "owned by another host\n");
+
+    for (const entry of ["crashed first", "crashed second"]) {
+      spawnSync(process.execPath, [process.argv[1], "crash", entry], {
+        timeout: 10000,
+      });
+    }
+
+    // the second crash took over the first one's log when it started
+    const left = (await fs.readdir(basePath)).filter((f) => f.endsWith(".wal"));
+    if (left.length !== 3) {
+      throw new Error(`Expected 3 write ahead logs, got ${left.join(", ")}`);
+    }
+    console.log("✓ Crashed processes left one write ahead log next to the others");
+
+    await Promise.all([restart(), restart()]);
+
+    const today = new Date().toISOString().split("T")[0];
+    const content = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+
+    for (const entry of ["crashed first", "crashed second"]) {
+      if (count(content, entry) !== 1) {
+        throw new Error(`Expected "${entry}" once, got ${count(content, entry)}`);
+      }
+    }
+    if (count(content, "after restart") !== 2) {
+      throw new Error(`Missing entries logged after restart: ${content}`);
+    }
+    console.log("✓ Crashed entries replayed once by two loggers starting together");
+
+    if (content.includes("running process") || content.includes("another host")) {
+      throw new Error("Replayed a write ahead log whose owner may be running");
+    }
+
+    const remaining = (await fs.readdir(basePath))
+      .filter((f) => f.endsWith(".wal"))
+      .sort();
+    if (remaining.join() !== [running, otherHost].sort().join()) {
+      throw new Error(`Unexpected write ahead logs: ${remaining.join(", ")}`);
+    }
+    console.log("✓ Logs of running owners kept, replayed and clean ones removed");
+
+    await fs.rm(basePath, { recursive: true, force: true });
+    await fs.mkdir(basePath, { recursive: true });
+
+    const samePid = spawnSync(process.execPath, [process.argv[1], "same-pid"], {
+      stdio: "inherit",
+    });
+    if (samePid.status !== 0) {
+      throw new Error(`Same pid restart exited with ${samePid.status}`);
+    }
+
+    const samePidContent = await fs.readFile(
+      path.join(basePath, `${today}.log`),
+      "utf-8",
+    );
+    if (
+      count(samePidContent, "left by the same pid") !== 1 ||
+      !samePidContent.includes("after same pid restart")
+    ) {
+      throw new Error(`Same pid restart wrote ${samePidContent}`);
+    }
+    if ((await fs.readdir(basePath)).some((f) => f.endsWith(".wal"))) {
+      throw new Error("Write ahead log left after a clean shutdown");
+    }
+    console.log("✓ Restart with the same pid replayed and kept using its log");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}
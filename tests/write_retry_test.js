@@ -0,0 +1,122 @@
+/**
+ * Test that failed writes are retried with backoff, and that entries are counted as dropped once
+ * every retry failed, using fail_writes.js to make the first writes fail with EIO
+ */
+
+import { Logger } from "../dist/index.js";
+import { spawnSync } from "child_process";
+import fs from "fs/promises";
+import path from "path";
+import { pathToFileURL } from "url";
+
+const basePath = "./write_retry_test";
+
+if (process.argv[2] === "child") {
+  const logger = new Logger({
+    saveToLogFiles: true,
+    outputToConsole: false,
+    basePath,
+    writeRetries: Number(process.argv[3]),
+  });
+
+  for (let i = 0; i < 3; i++) {
+    logger.info(`first write ${i}`);
+  }
+
+  // the retries back off 100ms, 200ms, 400ms
+  await new Promise((resolve) => setTimeout(resolve, 1000));
+  logger.info("second write");
+  await logger.flush();
+
+  const { dropped } = await logger.status();
+  console.log(JSON.stringify(dropped));
+  await logger.shutdown();
+} else {
+  main();
+}
+
+/**
+ * Runs a child whose first writes fail
+ * @param {number} failures How many writes fail
+ * @param {number} writeRetries How many times the worker retries
+ */
+function runChild(failures, writeRetries) {
+  const failWrites = pathToFileURL(path.resolve("tests/fail_writes.js"));
+  failWrites.search = new URLSearchParams({
+    code: "EIO",
+    count: String(failures),
+  }).toString();
+
+  const child = spawnSync(
+    process.execPath,
+    ["--import", failWrites.href, process.argv[1], "child", String(writeRetries)],
+    { encoding: "utf-8" },
+  );
+  if (child.status !== 0) {
+    throw new Error(`Child exited with ${child.status}: ${child.stderr}`);
+  }
+
+  return { dropped: JSON.parse(child.stdout), stderr: child.stderr };
+}
+
+/**
+ * Reads the entries written to today's log file
+ */
+async function readEntries() {
+  const today = new Date().toISOString().split("T")[0];
+  const content = await fs.readFile(
+    path.join(basePath, `${today}.log`),
+    "utf-8",
+  );
+  return content
+    .trim()
+    .split("\n")
+    .map((line) => line.split("[INFO]: ")[1]);
+}
+
+async function main() {
+  try {
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    let result = runChild(2, 3);
+    let entries = await readEntries();
+    const expected = ["first write 0", "first write 1", "first write 2", "second write"];
+    if (entries.join() !== expected.join()) {
+      throw new Error(`Expected ${expected.join(", ")}, got ${entries.join(", ")}`);
+    }
+    if (
+      result.stderr.includes("Write error") ||
+      result.stderr.includes("Stream error") ||
+      result.dropped.writeFailed
+    ) {
+      throw new Error(`Recovered write was reported: ${result.stderr}`);
+    }
+    console.log("✓ Write that failed twice was retried and written once");
+
+    await fs.rm(basePath, { recursive: true, force: true });
+
+    result = runChild(3, 2);
+    entries = await readEntries();
+    const expectedAfterDrop = [
+      "second write",
+      "Dropped entries since the last report: writeFailed=3",
+    ];
+    if (entries.join() !== expectedAfterDrop.join()) {
+      throw new Error(`Expected ${expectedAfterDrop.join(", ")}, got ${entries.join(", ")}`);
+    }
+    if (result.dropped.writeFailed !== 3) {
+      throw new Error(`Expected 3 writeFailed drops, got ${JSON.stringify(result.dropped)}`);
+    }
+    if (!result.stderr.includes("Write error: EIO")) {
+      throw new Error(`Failed write not reported: ${result.stderr}`);
+    }
+    console.log("✓ Write that failed every retry was reported and counted as dropped");
+
+    console.log("\n✅ All tests passed!");
+  } catch (error) {
+    console.error("\n❌ Test failed:", error.message);
+    process.exit(1);
+  } finally {
+    await fs.rm(basePath, { recursive: true, force: true });
+  }
+}